@@ -0,0 +1,580 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/ext"
+	"github.com/vdongchina/ratgo/utils/types"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 认证主体, 认证通过后存储于 gin.Context, 控制器通过 Principal() 读取
+type Principal struct {
+	Id     string                 `json:"id"`     // 用户或服务标识
+	Type   string                 `json:"type"`   // 认证方式: jwt | apikey | session
+	Roles  []string               `json:"roles"`  // 角色
+	Claims map[string]interface{} `json:"claims"` // 附加信息(jwt claims 等)
+}
+
+// 是否拥有任一角色
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		for _, value := range p.Roles {
+			if value == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gin.Context 中存储认证主体的key
+const principalKey = "principal"
+
+// 请求未携带对应凭证, 认证链继续尝试下一个认证器
+var ErrNoCredentials = errors.New("no credentials")
+
+// 认证器
+type Authenticator interface {
+	Authenticate(context *gin.Context) (*Principal, error)
+}
+
+// 认证策略
+type AuthPolicy struct {
+	Authenticators []Authenticator // 依次尝试, 首个通过的认证结果生效
+	Roles          []string        // 需拥有任一角色, 为空时不校验
+	Optional       bool            // 可选认证, 未携带凭证时放行
+}
+
+// 获取认证主体
+func GetPrincipal(context *gin.Context) (*Principal, bool) {
+	if value, ok := context.Get(principalKey); ok {
+		principal, ok := value.(*Principal)
+		return principal, ok
+	}
+	return nil, false
+}
+
+// 认证中间件, 认证失败响应 401, 角色不符响应 403
+func AuthMiddleWare(policy AuthPolicy) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var principal *Principal
+		for _, authenticator := range policy.Authenticators {
+			p, err := authenticator.Authenticate(context)
+			if err == ErrNoCredentials {
+				continue
+			} else if err != nil {
+				authFailed(context, http.StatusUnauthorized, err)
+				return
+			}
+			principal = p
+			break
+		}
+		if principal == nil {
+			if policy.Optional {
+				context.Next()
+				return
+			}
+			authFailed(context, http.StatusUnauthorized, ErrNoCredentials)
+			return
+		}
+		if len(policy.Roles) > 0 && !principal.HasRole(policy.Roles...) {
+			authFailed(context, http.StatusForbidden, errors.New(fmt.Sprintf("principal '%s' lacks roles %v", principal.Id, policy.Roles)))
+			return
+		}
+		context.Set(principalKey, principal)
+		context.Next()
+	}
+}
+
+// 认证失败响应
+func authFailed(context *gin.Context, status int, err error) {
+	context.Set("error", err)
+	context.Abort()
+	Render(context, NewResult().SetStatus(status).SetMsg(http.StatusText(status)))
+}
+
+/**************************************** JWT ****************************************/
+// jwt签名算法
+var jwtHashMap = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+}
+
+// JWT认证器, 支持 HS256/384/512、RS256/384/512
+type JWTAuthenticator struct {
+	Algorithm  string          // 签名算法, 仅接受该算法签名的token
+	Secret     []byte          // HS 密钥
+	PublicKey  *rsa.PublicKey  // RS 公钥
+	PrivateKey *rsa.PrivateKey // RS 私钥, 仅签发时使用
+	Issuer     string          // 校验 iss, 为空时不校验
+	Audience   string          // 校验 aud, 为空时不校验
+	Leeway     time.Duration   // exp、nbf 容许的时钟偏差
+	RolesClaim string          // 角色字段, 默认 roles
+	Header     string          // 读取 token 的请求头, 默认 Authorization(Bearer)
+	Query      string          // 读取 token 的 query 参数, 为空时不读取
+}
+
+// 认证
+func (ja *JWTAuthenticator) Authenticate(context *gin.Context) (*Principal, error) {
+	token := ja.token(context)
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+	claims, err := ja.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	rolesClaim := ja.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = "roles"
+	}
+	id, _ := claims["sub"].(string)
+	return &Principal{
+		Id:     id,
+		Type:   "jwt",
+		Roles:  optionStrings(claims[rolesClaim]),
+		Claims: claims,
+	}, nil
+}
+
+// 读取 token
+func (ja *JWTAuthenticator) token(context *gin.Context) string {
+	header := ja.Header
+	if header == "" {
+		header = "Authorization"
+	}
+	if value := context.GetHeader(header); value != "" {
+		if len(value) > 7 && strings.EqualFold(value[:7], "Bearer ") {
+			return strings.TrimSpace(value[7:])
+		}
+		return strings.TrimSpace(value)
+	}
+	if ja.Query != "" {
+		return context.Query(ja.Query)
+	}
+	return ""
+}
+
+// 校验 token 并返回 claims
+func (ja *JWTAuthenticator) Verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("jwt: malformed token")
+	}
+
+	// 校验 header 算法, 防止算法替换
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := jwtDecode(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != ja.Algorithm {
+		return nil, errors.New(fmt.Sprintf("jwt: unexpected algorithm '%s'", header.Alg))
+	}
+
+	// 校验签名
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("jwt: decode signature failed. error:%v", err))
+	}
+	if err := ja.verifySignature(parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	// 校验 claims
+	claims := map[string]interface{}{}
+	if err := jwtDecode(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(ja.Leeway)) {
+		return nil, errors.New("jwt: token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(ja.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("jwt: token is not valid yet")
+	}
+	if ja.Issuer != "" && claims["iss"] != ja.Issuer {
+		return nil, errors.New("jwt: invalid issuer")
+	}
+	if ja.Audience != "" {
+		found := false
+		for _, aud := range optionStrings(claims["aud"]) {
+			found = found || aud == ja.Audience
+		}
+		if !found {
+			return nil, errors.New("jwt: invalid audience")
+		}
+	}
+	return claims, nil
+}
+
+// 签发 token
+func (ja *JWTAuthenticator) Sign(claims map[string]interface{}) (string, error) {
+	headerBytes, _ := json.Marshal(map[string]string{"alg": ja.Algorithm, "typ": "JWT"})
+	claimsBytes, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingString := base64.RawURLEncoding.EncodeToString(headerBytes) + "." + base64.RawURLEncoding.EncodeToString(claimsBytes)
+	hash, ok := jwtHashMap[ja.Algorithm]
+	if !ok {
+		return "", errors.New(fmt.Sprintf("jwt: unsupported algorithm '%s'", ja.Algorithm))
+	}
+
+	var signature []byte
+	if strings.HasPrefix(ja.Algorithm, "HS") {
+		mac := hmac.New(hash.New, ja.Secret)
+		mac.Write([]byte(signingString))
+		signature = mac.Sum(nil)
+	} else {
+		if ja.PrivateKey == nil {
+			return "", errors.New("jwt: private key is required")
+		}
+		digest := hash.New()
+		digest.Write([]byte(signingString))
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, ja.PrivateKey, hash, digest.Sum(nil)); err != nil {
+			return "", err
+		}
+	}
+	return signingString + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// 校验签名
+func (ja *JWTAuthenticator) verifySignature(signingString string, signature []byte) error {
+	hash, ok := jwtHashMap[ja.Algorithm]
+	if !ok {
+		return errors.New(fmt.Sprintf("jwt: unsupported algorithm '%s'", ja.Algorithm))
+	}
+	if strings.HasPrefix(ja.Algorithm, "HS") {
+		mac := hmac.New(hash.New, ja.Secret)
+		mac.Write([]byte(signingString))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return errors.New("jwt: signature is invalid")
+		}
+		return nil
+	}
+	if ja.PublicKey == nil {
+		return errors.New("jwt: public key is required")
+	}
+	digest := hash.New()
+	digest.Write([]byte(signingString))
+	if err := rsa.VerifyPKCS1v15(ja.PublicKey, hash, digest.Sum(nil), signature); err != nil {
+		return errors.New("jwt: signature is invalid")
+	}
+	return nil
+}
+
+// 解码 jwt 片段
+func jwtDecode(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New(fmt.Sprintf("jwt: decode segment failed. error:%v", err))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return errors.New(fmt.Sprintf("jwt: unmarshal segment failed. error:%v", err))
+	}
+	return nil
+}
+
+// 读取 PEM 格式 RSA 公钥(PKIX、PKCS1 或证书)
+func LoadRSAPublicKey(filename string) (*rsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New(fmt.Sprintf("decode pem file '%s' failed", filename))
+	}
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if publicKey, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+			return publicKey, nil
+		}
+	default:
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if rsaKey, ok := publicKey.(*rsa.PublicKey); ok {
+			return rsaKey, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("'%s' is not a rsa public key", filename))
+}
+
+/**************************************** API Key ****************************************/
+// API Key 存储
+type APIKeyStore interface {
+	Lookup(key string) (*Principal, error) // key 不存在时返回 nil, nil
+}
+
+// 静态 API Key, key => 主体标识
+type StaticAPIKeyStore map[string]string
+
+// 查询
+func (ss StaticAPIKeyStore) Lookup(key string) (*Principal, error) {
+	for apiKey, id := range ss {
+		if hmac.Equal([]byte(apiKey), []byte(key)) {
+			return &Principal{Id: id}, nil
+		}
+	}
+	return nil, nil
+}
+
+// redis API Key 存储, key 为 prefix + sha256(apiKey), 值为主体标识或 Principal json
+type RedisAPIKeyStore struct {
+	Pool   *redis.Pool
+	Prefix string
+}
+
+// 查询
+func (rs *RedisAPIKeyStore) Lookup(key string) (*Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+	return redisPrincipal(rs.Pool, rs.Prefix+hex.EncodeToString(sum[:]))
+}
+
+// API Key 认证器
+type APIKeyAuthenticator struct {
+	Header string // 请求头, 默认 X-Api-Key
+	Query  string // query 参数, 为空时不读取
+	Store  APIKeyStore
+}
+
+// 认证
+func (aa *APIKeyAuthenticator) Authenticate(context *gin.Context) (*Principal, error) {
+	header := aa.Header
+	if header == "" {
+		header = "X-Api-Key"
+	}
+	key := context.GetHeader(header)
+	if key == "" && aa.Query != "" {
+		key = context.Query(aa.Query)
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	principal, err := aa.Store.Lookup(key)
+	if err != nil {
+		return nil, err
+	} else if principal == nil {
+		return nil, errors.New("invalid api key")
+	}
+	principal.Type = "apikey"
+	return principal, nil
+}
+
+/**************************************** Session ****************************************/
+// session 中存储认证主体的默认key
+const sessionPrincipalKey = "_principal"
+
+// session 认证器, 从 SessionManager 管理的 session(签名 cookie、同一存储)中读取主体, 需开启 session 中间件
+type SessionAuthenticator struct {
+	Key string // session 中存储主体的key, 默认 _principal
+}
+
+// 认证
+func (sa *SessionAuthenticator) Authenticate(context *gin.Context) (*Principal, error) {
+	session := GetSession(context)
+	if session == nil {
+		return nil, ErrNoCredentials
+	}
+	value := session.Get(sa.key()).Value()
+	if value == nil {
+		return nil, ErrNoCredentials
+	}
+	data, err := json.Marshal(value) // 文件、redis 存储读取后为 map
+	if err != nil {
+		return nil, err
+	}
+	principal := &Principal{}
+	if err := json.Unmarshal(data, principal); err != nil {
+		return nil, err
+	}
+	principal.Type = "session"
+	return principal, nil
+}
+
+// 登录: 更换 session id 后将主体写入 session, 须在响应写入前调用
+func (sa *SessionAuthenticator) Login(context *gin.Context, principal *Principal) error {
+	session := GetSession(context)
+	if session == nil {
+		return errors.New("session middleware is not registered")
+	}
+	data, err := json.Marshal(principal)
+	if err != nil {
+		return err
+	}
+	value := map[string]interface{}{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	session.Regenerate()
+	session.Set(sa.key(), value)
+	return nil
+}
+
+// 登出: 销毁 session 及 cookie
+func (sa *SessionAuthenticator) Logout(context *gin.Context) error {
+	if session := GetSession(context); session != nil {
+		session.Destroy()
+	}
+	return nil
+}
+
+func (sa *SessionAuthenticator) key() string {
+	if sa.Key == "" {
+		return sessionPrincipalKey
+	}
+	return sa.Key
+}
+
+// 从 redis 读取主体
+func redisPrincipal(pool *redis.Pool, key string) (*Principal, error) {
+	conn := pool.Get()
+	defer func() { _ = conn.Close() }()
+	data, err := redis.Bytes(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	principal := &Principal{}
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, principal); err != nil {
+			return nil, err
+		}
+	} else {
+		principal.Id = string(data)
+	}
+	return principal, nil
+}
+
+/**************************************** 配置 ****************************************/
+// 根据 ratgo-auth 配置注册分组认证策略
+func RegisterAuthMiddleWare() error {
+	authConfig := types.AnyMap(Config.Get("ratgo-auth").ToAnyMap())
+	if len(authConfig) == 0 {
+		return nil
+	}
+
+	// 认证器
+	authenticators := map[string]Authenticator{}
+	for name, value := range authConfig.Get("authenticator").ToAnyMap() {
+		conf, _ := value.(map[string]interface{})
+		authenticator, err := newAuthenticator(types.AnyMap(conf))
+		if err != nil {
+			return errors.New(fmt.Sprintf("auth authenticator '%s': %v", name, err))
+		}
+		authenticators[name] = authenticator
+	}
+
+	// 分组策略
+	for group, value := range authConfig.Get("policy").ToAnyMap() {
+		conf := types.AnyMap{}
+		if v, ok := value.(map[string]interface{}); ok {
+			conf = v
+		}
+		policy := AuthPolicy{
+			Roles:    optionStrings(conf.Get("Roles").Value()),
+			Optional: conf.Get("Optional").ToBool(),
+		}
+		for _, name := range optionStrings(conf.Get("Authenticators").Value()) {
+			authenticator, ok := authenticators[name]
+			if !ok {
+				return errors.New(fmt.Sprintf("auth policy '%s': undefined authenticator '%s'", group, name))
+			}
+			policy.Authenticators = append(policy.Authenticators, authenticator)
+		}
+		MiddleWare.SetGroup(group, AuthMiddleWare(policy))
+	}
+	return nil
+}
+
+// 根据配置创建认证器
+func newAuthenticator(conf types.AnyMap) (Authenticator, error) {
+	switch conf.Get("Type").ToString() {
+	case "jwt":
+		authenticator := &JWTAuthenticator{
+			Algorithm:  conf.Get("Algorithm").ToString(),
+			Secret:     []byte(conf.Get("Secret").ToString()),
+			Issuer:     conf.Get("Issuer").ToString(),
+			Audience:   conf.Get("Audience").ToString(),
+			Leeway:     time.Duration(optionInt(conf.Get("Leeway").Value())) * time.Second,
+			RolesClaim: conf.Get("RolesClaim").ToString(),
+			Header:     conf.Get("Header").ToString(),
+			Query:      conf.Get("Query").ToString(),
+		}
+		if _, ok := jwtHashMap[authenticator.Algorithm]; !ok {
+			return nil, errors.New(fmt.Sprintf("unsupported algorithm '%s'", authenticator.Algorithm))
+		}
+		if strings.HasPrefix(authenticator.Algorithm, "RS") {
+			publicKey, err := LoadRSAPublicKey(conf.Get("PublicKeyFile").ToString())
+			if err != nil {
+				return nil, err
+			}
+			authenticator.PublicKey = publicKey
+		} else if len(authenticator.Secret) == 0 {
+			return nil, errors.New("'Secret' can't be empty")
+		}
+		return authenticator, nil
+	case "apikey":
+		authenticator := &APIKeyAuthenticator{
+			Header: conf.Get("Header").ToString(),
+			Query:  conf.Get("Query").ToString(),
+		}
+		if identify := conf.Get("Redis").ToString(); identify != "" {
+			prefix := conf.Get("Prefix").ToString()
+			if prefix == "" {
+				prefix = "ratgo:apikey:"
+			}
+			authenticator.Store = &RedisAPIKeyStore{Pool: ext.Redis.Pool(identify), Prefix: prefix}
+		} else {
+			store := StaticAPIKeyStore{}
+			for key, id := range conf.Get("Keys").ToAnyMap() {
+				store[key] = fmt.Sprint(id)
+			}
+			authenticator.Store = store
+		}
+		return authenticator, nil
+	case "session":
+		if !Config.GetApp("session.Enable").ToBool() {
+			return nil, errors.New("requires ratgo session middleware, set 'session.Enable' to true")
+		}
+		return &SessionAuthenticator{Key: conf.Get("Key").ToString()}, nil
+	}
+	return nil, errors.New(fmt.Sprintf("unsupported type '%s'", conf.Get("Type").ToString()))
+}
@@ -0,0 +1,164 @@
+package ratgo
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/utils/types"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJWT_01(t *testing.T) {
+	ja := &JWTAuthenticator{Algorithm: "HS256", Secret: []byte("secret"), Issuer: "ratgo"}
+	token, err := ja.Sign(map[string]interface{}{"sub": "1001", "iss": "ratgo", "exp": time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ja.Verify(token)
+	if err != nil || claims["sub"] != "1001" {
+		t.Fatal(claims, err)
+	}
+
+	// 过期
+	expired, _ := ja.Sign(map[string]interface{}{"sub": "1001", "iss": "ratgo", "exp": time.Now().Add(-time.Minute).Unix()})
+	if _, err := ja.Verify(expired); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatal(err)
+	}
+
+	// 签名错误
+	other := &JWTAuthenticator{Algorithm: "HS256", Secret: []byte("other")}
+	forged, _ := other.Sign(map[string]interface{}{"sub": "1001", "iss": "ratgo"})
+	if _, err := ja.Verify(forged); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	if _, err := ja.Verify(parts[0] + "." + parts[1] + "."); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestJWT_02(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ja := &JWTAuthenticator{Algorithm: "RS256", PublicKey: &privateKey.PublicKey, PrivateKey: privateKey}
+	token, err := ja.Sign(map[string]interface{}{"sub": "1001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ja.Verify(token); err != nil {
+		t.Fatal(err)
+	}
+
+	// 算法替换: 使用公钥作为 HS256 密钥签名
+	publicKey, _ := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	swapped, _ := (&JWTAuthenticator{Algorithm: "HS256", Secret: publicKey}).Sign(map[string]interface{}{"sub": "admin"})
+	if _, err := ja.Verify(swapped); err == nil || !strings.Contains(err.Error(), "algorithm") {
+		t.Fatal(err)
+	}
+
+	// alg none
+	parts := strings.Split(token, ".")
+	if _, err := ja.Verify("eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0." + parts[1] + "."); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestAuthMiddleWare_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ja := &JWTAuthenticator{Algorithm: "HS256", Secret: []byte("secret")}
+	aa := &APIKeyAuthenticator{Store: StaticAPIKeyStore{"key-admin": "admin", "key-guest": "guest"}}
+	admin, _ := ja.Sign(map[string]interface{}{"sub": "1001", "roles": []string{"admin"}})
+	guest, _ := ja.Sign(map[string]interface{}{"sub": "1002", "roles": []string{"guest"}})
+	engine := gin.New()
+	handler := func(context *gin.Context) {
+		id := "anonymous"
+		if principal, ok := GetPrincipal(context); ok {
+			id = principal.Type + ":" + principal.Id
+		}
+		context.String(http.StatusOK, id)
+	}
+	engine.GET("/admin", AuthMiddleWare(AuthPolicy{Authenticators: []Authenticator{ja, aa}, Roles: []string{"admin"}}), handler)
+	engine.GET("/user", AuthMiddleWare(AuthPolicy{Authenticators: []Authenticator{ja, aa}}), handler)
+	engine.GET("/public", AuthMiddleWare(AuthPolicy{Authenticators: []Authenticator{ja}, Optional: true}), handler)
+
+	cases := []struct {
+		path   string
+		header map[string]string
+		status int
+		body   string
+	}{
+		{"/admin", map[string]string{"Authorization": "Bearer " + admin}, http.StatusOK, "jwt:1001"},
+		{"/admin", map[string]string{"Authorization": "Bearer " + guest}, http.StatusForbidden, ""},
+		{"/admin", map[string]string{"Authorization": "Bearer bad.token.value"}, http.StatusUnauthorized, ""},
+		{"/admin", nil, http.StatusUnauthorized, ""},
+		{"/user", map[string]string{"X-Api-Key": "key-guest"}, http.StatusOK, "apikey:guest"},
+		{"/user", map[string]string{"X-Api-Key": "key-other"}, http.StatusUnauthorized, ""},
+		{"/public", nil, http.StatusOK, "anonymous"},
+		{"/public", map[string]string{"Authorization": "Bearer " + guest}, http.StatusOK, "jwt:1002"},
+		{"/public", map[string]string{"Authorization": "Bearer bad.token.value"}, http.StatusUnauthorized, ""}, // 可选认证仍拒绝无效凭证
+	}
+	for _, c := range cases {
+		recorder := testServe(engine, http.MethodGet, c.path, c.header)
+		if recorder.Code != c.status || (c.body != "" && recorder.Body.String() != c.body) {
+			t.Errorf("%s %v receive %d %s", c.path, c.header, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestRegisterAuthMiddleWare_01(t *testing.T) {
+	_, restore := testWebServer()
+	defer restore()
+	defer func(definedConfig, appConfig types.AnyMap) {
+		Config.DefinedConfig, Config.appConfig = definedConfig, appConfig
+	}(Config.DefinedConfig, Config.appConfig)
+	Config.appConfig = types.AnyMap{}
+
+	// 根据配置注册分组认证中间件
+	Config.DefinedConfig = types.AnyMap{"ratgo-auth": map[string]interface{}{
+		"authenticator": map[string]interface{}{
+			"token": map[string]interface{}{"Type": "jwt", "Algorithm": "HS256", "Secret": "secret"},
+			"key":   map[string]interface{}{"Type": "apikey", "Keys": map[string]interface{}{"key-1": "svc"}},
+		},
+		"policy": map[string]interface{}{
+			"/api/":  map[string]interface{}{"Authenticators": "token,key", "Roles": []interface{}{"admin"}},
+			"/open/": map[string]interface{}{"Authenticators": []interface{}{"key"}, "Optional": true},
+		},
+	}}
+	if err := RegisterAuthMiddleWare(); err != nil {
+		t.Fatal(err)
+	}
+	if len(MiddleWare.group) != 2 {
+		t.Fatal(MiddleWare.group)
+	}
+
+	// 错误配置
+	cases := map[string]map[string]interface{}{
+		"undefined authenticator 'missing'": {
+			"policy": map[string]interface{}{"/api/": map[string]interface{}{"Authenticators": "missing"}},
+		},
+		"unsupported type 'basic'": {
+			"authenticator": map[string]interface{}{"basic": map[string]interface{}{"Type": "basic"}},
+		},
+		"'Secret' can't be empty": {
+			"authenticator": map[string]interface{}{"token": map[string]interface{}{"Type": "jwt", "Algorithm": "HS256"}},
+		},
+		"unsupported algorithm 'none'": {
+			"authenticator": map[string]interface{}{"token": map[string]interface{}{"Type": "jwt", "Algorithm": "none"}},
+		},
+		"session.Enable": {
+			"authenticator": map[string]interface{}{"login": map[string]interface{}{"Type": "session"}},
+		},
+	}
+	for expect, authConfig := range cases {
+		Config.DefinedConfig = types.AnyMap{"ratgo-auth": authConfig}
+		if err := RegisterAuthMiddleWare(); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("expect '%s', but receive %v", expect, err)
+		}
+	}
+}
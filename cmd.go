@@ -8,3 +8,278 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 package ratgo
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/vdongchina/ratgo/utils/encrypt"
+	"github.com/vdongchina/ratgo/utils/vdlog"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+/**************************************** 命令接口 ****************************************/
+// 命令接口
+type CommandInterface interface {
+	Init(name string, args []string, logger *vdlog.Logger) // 初始化
+	Usage() string                                         // 命令说明
+	Flags(flagSet *flag.FlagSet)                           // 注册命令参数(绑定至命令结构体字段)
+	BeforeExec() error                                     // 动作前执行方法
+	Exec() error                                           // 动作方法
+}
+
+// 命令基类
+type Command struct {
+	name   string
+	args   []string
+	logger *vdlog.Logger
+}
+
+// 命令初始化方法
+func (c *Command) Init(name string, args []string, logger *vdlog.Logger) {
+	c.name = name
+	c.args = args
+	c.logger = logger
+}
+
+// 获取命令名称
+func (c *Command) Name() string {
+	return c.name
+}
+
+// 获取解析参数后剩余的位置参数
+func (c *Command) Args() []string {
+	return c.args
+}
+
+// 获取日志对象(已设置 logId)
+func (c *Command) Logger() *vdlog.Logger {
+	return c.logger
+}
+
+// 命令说明
+func (c *Command) Usage() string {
+	return ""
+}
+
+// 注册命令参数
+func (c *Command) Flags(flagSet *flag.FlagSet) {
+
+}
+
+// 前置方法
+func (c *Command) BeforeExec() error {
+	return nil
+}
+
+// 执行方法
+func (c *Command) Exec() error {
+	return nil
+}
+
+/**************************************** 命令存储器 ****************************************/
+// 命令容器
+type CommandMap map[string]CommandInterface
+
+// 命令存储器
+type CmdStorage struct {
+	commandMap CommandMap
+}
+
+// cmd对象
+var Cmd *CmdStorage
+
+func init() {
+	Cmd = &CmdStorage{
+		commandMap: CommandMap{},
+	}
+	Cmd.Register("", CommandMap{"routes": &routesCommand{}})               // 内置命令: 输出路由表
+	Cmd.Register("config", CommandMap{"encrypt": &configEncryptCommand{}}) // 内置命令: 加密配置值
+}
+
+// 注册命令, group不为空时命令名称为 group:name
+func (cs *CmdStorage) Register(group string, commands CommandMap) {
+	group = strings.Trim(strings.TrimSpace(group), ":")
+	for key, value := range commands {
+		key = strings.Trim(strings.TrimSpace(key), ":")
+		if group != "" {
+			key = fmt.Sprintf("%s:%s", group, key)
+		}
+		cs.commandMap[key] = value // 存储命令模板
+	}
+}
+
+// 获取命令
+func (cs *CmdStorage) Get(name string) CommandInterface {
+	if command, ok := cs.commandMap[strings.TrimSpace(name)]; ok {
+		return command
+	}
+	return nil
+}
+
+// 获取全部命令
+func (cs *CmdStorage) GetAll() CommandMap {
+	return cs.commandMap
+}
+
+// 获取排序后的命令名称
+func (cs *CmdStorage) Names() []string {
+	names := make([]string, 0, len(cs.commandMap))
+	for key := range cs.commandMap {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/**************************************** 命令服务 ****************************************/
+// Cmd server.
+type CmdServer struct {
+	output io.Writer
+}
+
+// 获取cmd实例
+func NewCmdServer() *CmdServer {
+	return &CmdServer{
+		output: os.Stdout,
+	}
+}
+
+// 初始化
+func (cs *CmdServer) Init() {
+	tag := "CmdServer初始化"
+	initConfig(tag)   // 配置初始化
+	initResource(tag) // 初始化 mysql、redis
+	_ = initLogger()  // 系统日志
+	runUserFunc()     // 执行用户挂载函数
+}
+
+// 设置输出
+func (cs *CmdServer) SetOutput(output io.Writer) *CmdServer {
+	cs.output = output
+	return cs
+}
+
+// 运行命令, args 不包含程序名称
+func (cs *CmdServer) Run(args []string) (err error) {
+	// 内置 help 命令及未定义命令
+	if handled, err := cs.Help(args); handled {
+		return err
+	}
+
+	// 克隆结构体
+	name := args[0]
+	command, flagSet := cs.clone(name, Cmd.Get(name), true)
+	if err = flagSet.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	// 异常捕获
+	defer func() {
+		if r := recover(); r != nil {
+			err = Error(r)
+		}
+	}()
+
+	// 日志
+	logId := encrypt.Md5(name, fmt.Sprintf("%d", time.Now().UnixNano())) // 生成 logId
+	logger := vdlog.Clone().SetLogId(logId)
+	command.Init(name, flagSet.Args(), logger)
+
+	// 执行 BeforeExec()
+	if err = command.BeforeExec(); err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+
+	// 执行 Exec()
+	if err = command.Exec(); err != nil {
+		logger.Error(err.Error())
+	}
+	return err
+}
+
+// 克隆命令并注册参数, inject 为 false 时不注入依赖(仅用于打印用法)
+func (cs *CmdServer) clone(name string, commandTpl CommandInterface, inject bool) (CommandInterface, *flag.FlagSet) {
+	cmdType := reflect.TypeOf(commandTpl).Elem()
+	command, ok := reflect.New(cmdType).Interface().(CommandInterface)
+	if !ok {
+		panic("command is not CommandInterface")
+	}
+	if inject {
+		if err := AppStorage.Inject(command, nil); err != nil { // 注入 inject 标签字段
+			panic(err)
+		}
+	}
+	flagSet := flag.NewFlagSet(name, flag.ContinueOnError)
+	flagSet.SetOutput(cs.output)
+	command.Flags(flagSet)
+	flagSet.Usage = func() {
+		cs.printUsage(name, command, flagSet)
+	}
+	return command, flagSet
+}
+
+// 处理 help、命令用法(<command> -h)及未定义命令, 已处理时返回 true, 无需初始化配置及资源
+func (cs *CmdServer) Help(args []string) (bool, error) {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		if len(args) > 1 {
+			return true, cs.usage(args[1])
+		}
+		cs.help()
+		return true, nil
+	}
+	if Cmd.Get(args[0]) == nil {
+		cs.help()
+		return true, errors.New(fmt.Sprintf("command '%s' is not defined", args[0]))
+	}
+	if len(args) > 1 && (args[1] == "-h" || args[1] == "-help" || args[1] == "--help") {
+		return true, cs.usage(args[0])
+	}
+	return false, nil
+}
+
+// 打印命令列表
+func (cs *CmdServer) help() {
+	_, _ = fmt.Fprintf(cs.output, "Usage:\n  %s <command> [flags] [args]\n\n", filepath.Base(os.Args[0]))
+	_, _ = fmt.Fprintf(cs.output, "Available commands:\n")
+	_, _ = fmt.Fprintf(cs.output, "  %-20s %s\n", "help", "Show command list or usage of a command")
+	for _, name := range Cmd.Names() {
+		usage := strings.SplitN(Cmd.Get(name).Usage(), "\n", 2)[0]
+		_, _ = fmt.Fprintf(cs.output, "  %-20s %s\n", name, usage)
+	}
+}
+
+// 打印单个命令用法
+func (cs *CmdServer) usage(name string) error {
+	commandTpl := Cmd.Get(name)
+	if commandTpl == nil {
+		return errors.New(fmt.Sprintf("command '%s' is not defined", name))
+	}
+	command, flagSet := cs.clone(name, commandTpl, false)
+	cs.printUsage(name, command, flagSet)
+	return nil
+}
+
+// 输出命令用法
+func (cs *CmdServer) printUsage(name string, command CommandInterface, flagSet *flag.FlagSet) {
+	_, _ = fmt.Fprintf(cs.output, "Usage:\n  %s %s [flags] [args]\n", filepath.Base(os.Args[0]), name)
+	if usage := command.Usage(); usage != "" {
+		_, _ = fmt.Fprintf(cs.output, "\n%s\n", usage)
+	}
+	hasFlag := false
+	flagSet.VisitAll(func(*flag.Flag) { hasFlag = true })
+	if hasFlag {
+		_, _ = fmt.Fprintf(cs.output, "\nFlags:\n")
+		flagSet.PrintDefaults()
+	}
+}
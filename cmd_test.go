@@ -0,0 +1,114 @@
+package ratgo
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+type testCommand struct {
+	Command
+	Config *ConfigStorage `inject:"config"`
+	Times  int
+	Fail   bool
+}
+
+func (c *testCommand) Usage() string {
+	return "test command\nsecond line"
+}
+
+func (c *testCommand) Flags(flagSet *flag.FlagSet) {
+	flagSet.IntVar(&c.Times, "times", 1, "repeat times")
+	flagSet.BoolVar(&c.Fail, "fail", false, "return error")
+}
+
+func (c *testCommand) Exec() error {
+	if c.Config != Config {
+		return errors.New("config is not injected")
+	}
+	if c.Fail {
+		return errors.New("exec failed")
+	}
+	testCommandRuns = append(testCommandRuns, c)
+	return nil
+}
+
+var testCommandRuns []*testCommand
+
+func TestCmdServerHelp_01(t *testing.T) {
+	Cmd.Register("test", CommandMap{"run": &testCommand{}})
+	defer delete(Cmd.commandMap, "test:run")
+	output := &bytes.Buffer{}
+	cs := NewCmdServer().SetOutput(output)
+
+	cases := []struct {
+		args     []string
+		handled  bool
+		hasError bool
+		contains []string
+	}{
+		{nil, true, false, []string{"Available commands:", "test:run", "test command", "routes"}},
+		{[]string{"help"}, true, false, []string{"Available commands:"}},
+		{[]string{"help", "test:run"}, true, false, []string{"test:run [flags]", "second line", "-times"}},
+		{[]string{"help", "missing"}, true, true, nil},
+		{[]string{"missing"}, true, true, []string{"Available commands:"}},
+		{[]string{"test:run", "-h"}, true, false, []string{"test:run [flags]", "-fail"}},
+		{[]string{"test:run", "-times", "2"}, false, false, nil},
+	}
+	for _, c := range cases {
+		output.Reset()
+		handled, err := cs.Help(c.args)
+		if handled != c.handled || (err != nil) != c.hasError {
+			t.Errorf("%v receive %v %v", c.args, handled, err)
+		}
+		for _, s := range c.contains {
+			if !strings.Contains(output.String(), s) {
+				t.Errorf("%v output missing '%s': %s", c.args, s, output.String())
+			}
+		}
+	}
+
+	// 命令列表仅显示说明首行
+	output.Reset()
+	_, _ = cs.Help(nil)
+	if strings.Contains(output.String(), "second line") {
+		t.Error(output.String())
+	}
+}
+
+func TestCmdServerRun_01(t *testing.T) {
+	Cmd.Register("test", CommandMap{"run": &testCommand{}})
+	defer delete(Cmd.commandMap, "test:run")
+	testCommandRuns = nil
+	cs := NewCmdServer().SetOutput(&bytes.Buffer{})
+
+	// 每次运行克隆命令并注入依赖, 不修改命令模板
+	if err := cs.Run([]string{"test:run", "-times", "3", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Run([]string{"test:run"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(testCommandRuns) != 2 || testCommandRuns[0] == testCommandRuns[1] {
+		t.Fatal(testCommandRuns)
+	}
+	first, second := testCommandRuns[0], testCommandRuns[1]
+	if first.Times != 3 || first.Name() != "test:run" || strings.Join(first.Args(), ",") != "a,b" || first.Logger() == nil {
+		t.Errorf("receive %+v", first)
+	}
+	if second.Times != 1 || len(second.Args()) != 0 {
+		t.Errorf("receive %+v", second)
+	}
+	if template := Cmd.Get("test:run").(*testCommand); template.Config != nil || template.Times != 0 {
+		t.Errorf("template changed %+v", template)
+	}
+
+	// 执行错误、参数错误及未定义命令
+	for _, args := range [][]string{{"test:run", "-fail"}, {"test:run", "-times", "x"}, {"missing"}} {
+		if err := cs.Run(args); err == nil {
+			t.Errorf("%v expect error", args)
+		}
+	}
+}
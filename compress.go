@@ -0,0 +1,420 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"hash/crc32"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 压缩及 ETag 配置, 对应 ratgo 配置 compress 节点
+type CompressOption struct {
+	Enable       bool
+	Encodings    []string // 支持的编码, 按优先级排列, 为空时仅计算 ETag
+	Level        int      // 压缩级别, 0 使用编码器默认级别
+	MinLength    int      // 最小压缩字节数
+	ContentTypes []string // 允许压缩的内容类型, 支持 text/* 通配
+	MaxBuffer    int      // 最大缓冲字节数, 超出时直接输出, 不压缩、不计算 ETag
+	ETag         bool     // 是否计算 ETag 并响应 304
+	WeakETag     bool     // 使用弱 ETag(W/"长度-crc32"), 默认强 ETag(sha256)
+}
+
+// 压缩编码器, 返回写入压缩数据的 io.WriteCloser
+type Encoder func(writer io.Writer, level int) (io.WriteCloser, error)
+
+// 压缩中间件优先级, 在链路追踪、监控指标之后执行, 以缓冲其他中间件的输出
+const compressMiddleWarePriority = 900
+
+var (
+	encoderLock sync.RWMutex
+	encoders    = map[string]Encoder{
+		"gzip": func(writer io.Writer, level int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = gzip.DefaultCompression
+			}
+			return gzip.NewWriterLevel(writer, level)
+		},
+		"deflate": func(writer io.Writer, level int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = flate.DefaultCompression
+			}
+			return flate.NewWriter(writer, level)
+		},
+	}
+)
+
+// 注册压缩编码器(如 br), 相同名称覆盖, 需在 Encodings 中配置后生效
+func RegisterEncoder(name string, encoder Encoder) {
+	encoderLock.Lock()
+	defer encoderLock.Unlock()
+	encoders[strings.ToLower(name)] = encoder
+}
+
+// 获取压缩编码器
+func getEncoder(name string) (Encoder, bool) {
+	encoderLock.RLock()
+	defer encoderLock.RUnlock()
+	encoder, ok := encoders[name]
+	return encoder, ok
+}
+
+// 默认压缩配置
+func DefaultCompressOption() CompressOption {
+	return CompressOption{
+		Encodings: []string{"gzip", "deflate"},
+		MinLength: 1024,
+		ContentTypes: []string{
+			"text/*",
+			"application/json",
+			"application/javascript",
+			"application/xml",
+			"application/x-yaml",
+			"application/yaml",
+			"image/svg+xml",
+		},
+		MaxBuffer: 8 << 20,
+		ETag:      true,
+	}
+}
+
+// 根据 ratgo 配置注册压缩中间件, 配置 compress.Enable 为 true 时生效
+func RegisterCompressMiddleWare() error {
+	option := DefaultCompressOption()
+	if loadOption(&option, Config.GetApp("compress").ToAnyMap()); option.Enable {
+		MiddleWare.SetGlobalPriority(compressMiddleWarePriority, CompressMiddleWare(option))
+	}
+	return nil
+}
+
+// 压缩及 ETag 中间件: 缓冲响应, 按 Accept-Encoding 协商压缩, 按 If-None-Match 响应 304
+func CompressMiddleWare(option CompressOption) gin.HandlerFunc {
+	for i, encoding := range option.Encodings {
+		option.Encodings[i] = strings.ToLower(strings.TrimSpace(encoding))
+	}
+	return func(context *gin.Context) {
+		if context.GetHeader("Upgrade") != "" { // websocket等协议升级
+			context.Next()
+			return
+		}
+		writer := &compressWriter{ResponseWriter: context.Writer, option: &option, status: context.Writer.Status()}
+		context.Writer = writer
+		defer writer.finish(context)
+		context.Next()
+	}
+}
+
+// 缓冲响应的 ResponseWriter
+type compressWriter struct {
+	gin.ResponseWriter
+	option      *CompressOption
+	buffer      bytes.Buffer
+	status      int
+	wroteHeader bool // 是否已调用 WriteHeaderNow 或写入数据
+	passthrough bool // 直接输出(超出缓冲、Flush、Hijack)
+}
+
+// 记录状态码
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.passthrough {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 && !cw.wroteHeader {
+		cw.status = code
+	}
+}
+
+// 标记响应头已写入
+func (cw *compressWriter) WriteHeaderNow() {
+	if cw.passthrough {
+		cw.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	cw.wroteHeader = true
+}
+
+// 写入缓冲, 超出 MaxBuffer 时转为直接输出
+func (cw *compressWriter) Write(data []byte) (int, error) {
+	if cw.passthrough {
+		return cw.ResponseWriter.Write(data)
+	}
+	cw.wroteHeader = true
+	cw.buffer.Write(data)
+	if cw.option.MaxBuffer > 0 && cw.buffer.Len() > cw.option.MaxBuffer {
+		if err := cw.startPassthrough(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// 写入字符串
+func (cw *compressWriter) WriteString(s string) (int, error) {
+	return cw.Write([]byte(s))
+}
+
+// 状态码
+func (cw *compressWriter) Status() int {
+	if cw.passthrough {
+		return cw.ResponseWriter.Status()
+	}
+	return cw.status
+}
+
+// 已写入字节数, 未写入时为 -1
+func (cw *compressWriter) Size() int {
+	if cw.passthrough {
+		return cw.ResponseWriter.Size()
+	}
+	if !cw.wroteHeader {
+		return -1
+	}
+	return cw.buffer.Len()
+}
+
+// 是否已写入
+func (cw *compressWriter) Written() bool {
+	return cw.Size() != -1
+}
+
+// 流式输出, 转为直接输出
+func (cw *compressWriter) Flush() {
+	_ = cw.startPassthrough()
+	cw.ResponseWriter.Flush()
+}
+
+// 接管连接, 转为直接输出
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	cw.passthrough = true
+	return cw.ResponseWriter.Hijack()
+}
+
+// 输出已缓冲的数据并转为直接输出
+func (cw *compressWriter) startPassthrough() error {
+	if cw.passthrough {
+		return nil
+	}
+	cw.passthrough = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buffer.Len() == 0 {
+		cw.ResponseWriter.WriteHeaderNow()
+		return nil
+	}
+	_, err := cw.ResponseWriter.Write(cw.buffer.Bytes())
+	cw.buffer.Reset()
+	return err
+}
+
+// 处理缓冲的响应: 计算 ETag、协商压缩并输出
+func (cw *compressWriter) finish(context *gin.Context) {
+	context.Writer = cw.ResponseWriter
+	if cw.passthrough {
+		return
+	}
+	writer := cw.ResponseWriter
+	header := writer.Header()
+	body := cw.buffer.Bytes()
+	status := cw.status
+
+	// 压缩编码
+	encoding := ""
+	if cw.compressible(header, status, body) {
+		addVary(header, "Accept-Encoding")
+		encoding = negotiateEncoding(context.GetHeader("Accept-Encoding"), cw.option.Encodings)
+	}
+
+	// ETag, 处理器已设置时沿用
+	etag := header.Get("ETag")
+	if etag == "" && cw.option.ETag && status == http.StatusOK && len(body) > 0 && context.Request.Method == http.MethodGet {
+		etag = computeETag(body, cw.option.WeakETag)
+	}
+	if etag != "" {
+		if encoding != "" {
+			etag = encodingETag(etag, encoding)
+		}
+		header.Set("ETag", etag)
+		if status == http.StatusOK && etagMatch(context.GetHeader("If-None-Match"), etag) {
+			header.Del("Content-Length")
+			header.Del("Content-Encoding")
+			writer.WriteHeader(http.StatusNotModified)
+			writer.WriteHeaderNow()
+			return
+		}
+	}
+
+	// 压缩
+	if encoding != "" {
+		if compressed, err := compressBody(body, encoding, cw.option.Level); err == nil {
+			body = compressed
+			header.Set("Content-Encoding", encoding)
+			header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+	}
+
+	writer.WriteHeader(status)
+	if cw.wroteHeader {
+		writer.WriteHeaderNow()
+		if len(body) > 0 {
+			_, _ = writer.Write(body)
+		}
+	}
+}
+
+// 响应是否可压缩: 状态码、大小、未编码、内容类型
+func (cw *compressWriter) compressible(header http.Header, status int, body []byte) bool {
+	if len(cw.option.Encodings) == 0 || len(body) == 0 || len(body) < cw.option.MinLength {
+		return false
+	}
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	return contentTypeAllowed(cw.option.ContentTypes, contentType)
+}
+
+// 内容类型是否在允许列表中, 支持 text/* 通配
+func contentTypeAllowed(allowTypes []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowType := range allowTypes {
+		allowType = strings.ToLower(strings.TrimSpace(allowType))
+		if allowType == mediaType || allowType == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(allowType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowType, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// 按 Accept-Encoding 的 q 值协商编码, q 值相同时按 encodings 顺序
+func negotiateEncoding(acceptEncoding string, encodings []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	accepted := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, q := strings.TrimSpace(part), 1.0
+		if index := strings.Index(name, ";"); index >= 0 {
+			if param := strings.TrimSpace(name[index+1:]); strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+			name = strings.TrimSpace(name[:index])
+		}
+		accepted[strings.ToLower(name)] = q
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		if _, ok := getEncoder(encoding); !ok {
+			continue
+		}
+		q, ok := accepted[encoding]
+		if !ok {
+			q = accepted["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// 压缩数据
+func compressBody(body []byte, encoding string, level int) ([]byte, error) {
+	encoder, ok := getEncoder(encoding)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unsupported encoding '%s'", encoding))
+	}
+	buffer := &bytes.Buffer{}
+	writer, err := encoder(buffer, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(body); err != nil {
+		_ = writer.Close()
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// 计算 ETag
+func computeETag(body []byte, weak bool) string {
+	if weak {
+		return fmt.Sprintf(`W/"%x-%08x"`, len(body), crc32.ChecksumIEEE(body))
+	}
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// 压缩后的强 ETag 追加编码后缀, 弱 ETag 不变
+func encodingETag(etag string, encoding string) string {
+	if strings.HasPrefix(etag, "W/") || !strings.HasSuffix(etag, `"`) || strings.HasSuffix(etag, "-"+encoding+`"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// If-None-Match 弱比较
+func etagMatch(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// 追加 Vary 响应头, 已存在时跳过
+func addVary(header http.Header, value string) {
+	for _, vary := range header.Values("Vary") {
+		for _, v := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
@@ -0,0 +1,77 @@
+package ratgo
+
+import (
+	"compress/gzip"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding_01(t *testing.T) {
+	encodings := []string{"gzip", "deflate"}
+	cases := map[string]string{
+		"":                       "",
+		"gzip, deflate":          "gzip",
+		"deflate":                "deflate",
+		"gzip;q=0.5, deflate":    "deflate",
+		"gzip;q=0, deflate;q=0":  "",
+		"*":                      "gzip",
+		"br":                     "",
+		"GZIP;q=0.8, br;q=1":     "gzip",
+		"identity, deflate;q=.1": "deflate",
+	}
+	for acceptEncoding, expect := range cases {
+		if encoding := negotiateEncoding(acceptEncoding, encodings); encoding != expect {
+			t.Errorf("'%s' expect '%s', but receive '%s'", acceptEncoding, expect, encoding)
+		}
+	}
+}
+
+func TestCompressMiddleWare_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := strings.Repeat("ratgo ", 500)
+	engine := gin.New()
+	engine.Use(CompressMiddleWare(DefaultCompressOption()))
+	engine.GET("/", func(context *gin.Context) {
+		context.String(http.StatusOK, body)
+	})
+	request := func(header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// gzip 压缩
+	recorder := request(map[string]string{"Accept-Encoding": "gzip"})
+	if recorder.Header().Get("Content-Encoding") != "gzip" || !strings.Contains(recorder.Header().Get("Vary"), "Accept-Encoding") {
+		t.Fatal(recorder.Header())
+	}
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadAll(reader); string(data) != body {
+		t.Fatal("decompressed body mismatch")
+	}
+
+	// ETag 及 304, 不同编码的 ETag 不同
+	etag := recorder.Header().Get("ETag")
+	plain := request(nil)
+	if etag == "" || plain.Header().Get("ETag") == etag || plain.Body.String() != body {
+		t.Fatal(etag, plain.Header())
+	}
+	recorder = request(map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+	if recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+		t.Fatal(recorder.Code, recorder.Body.Len())
+	}
+	if recorder = request(map[string]string{"If-None-Match": etag}); recorder.Code != http.StatusOK {
+		t.Fatal(recorder.Code)
+	}
+}
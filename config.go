@@ -10,72 +10,94 @@
 package ratgo
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/vdongchina/ratgo/config"
 	"github.com/vdongchina/ratgo/utils/types"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // ConfigStorage.
 type ConfigStorage struct {
-	AppName        string // Application name
-	AppVersion     string // Application version
-	RunMode        string // Running Mode: dev | test | prod
-	AppPath        string
-	ConfigPath     string
-	RuntimePath    string
-	RuntimeLogPath string
-	EnableHTTP     bool
-	HTTPAddr       string
-	EnableHTTPS    bool
-	HTTPSAddr      string
-	HTTPSCertFile  string
-	HTTPSKeyFile   string
-	RecoverPanic   bool
-	RecoverFunc    func(c *gin.Context)
-	DefinedConfig  types.AnyMap
-	Error          error
-	Pattern        string // debug:list; release
-	StoreFormat    int    // 1:text; 2:json;
-	Storage        string // local; syslog; redis; es; mongo
-	ProjectName    string // 项目名称
-	InitDb         bool   // 是否初始化 gorm db
-	InitRedis      bool   // 是否初始化 redis
-	HandleFunc     func(config *ConfigStorage) error
+	AppName          string // Application name
+	AppVersion       string // Application version
+	RunMode          string // Running Mode: dev | test | prod
+	AppPath          string
+	ConfigPath       string
+	RuntimePath      string
+	RuntimeLogPath   string
+	EnableHTTP       bool
+	HTTPAddr         string
+	EnableHTTPS      bool
+	HTTPSAddr        string
+	HTTPSCertFile    string
+	HTTPSKeyFile     string
+	HTTPSRedirect    bool                 // 同时开启http、https时, http请求重定向至https
+	WebsocketPath    string               // websocket连接路径, 多个使用,拼接
+	ShutdownTimeout  int                  // 优雅关闭等待请求处理完成的超时时间(秒)
+	StatusMapping    string               // 状态码映射, 格式: 900:200,901:200
+	ResponseEnvelope bool                 // 是否使用响应信封 {code,msg,data}, 可通过 SetEnvelope 自定义
+	AdminPath        string               // 管理接口路径(如 /_admin), 为空时不开启
+	AdminToken       string               // 管理接口令牌, 请求头 X-Admin-Token
+	TrustedProxies   string               // 可信代理 ip/cidr, 逗号分隔, 配置后从 X-Forwarded-For 解析客户端ip
+	RecoverPanic     bool                 // 是否捕获控制器 panic
+	RecoverFunc      func(c *gin.Context) // 自定义异常响应, 可通过 GetError(c) 获取错误
+	DefinedConfig    types.AnyMap
+	Error            error
+	Pattern          string // debug:list; release
+	StoreFormat      int    // 1:text; 2:json;
+	Storage          string // local; syslog; redis; es; mongo
+	ProjectName      string // 项目名称
+	InitDb           bool   // 是否初始化 gorm db
+	InitRedis        bool   // 是否初始化 redis
+	HandleFunc       func(config *ConfigStorage) error
+	appConfig        types.AnyMap            // ratgo 配置, Init 后从 DefinedConfig 中移除, 通过 GetApp 读取
+	sources          map[string]ConfigSource // 配置来源, key 为 . 拼接的配置路径
+	definedMap       map[string]interface{}  // Init 传入的配置, 重新加载时使用
+	runtimeSets      []runtimeSet            // 运行期间 Set 的配置, 重新加载后保留
+	subscribers      []configSubscriber      // 配置变更订阅者
+	watchStop        chan struct{}           // 停止监听配置目录
+	secrets          map[string]bool         // 由占位符、加密值解析的配置路径, 打印时替换为掩码
+	lock             sync.RWMutex
 }
 
 var (
 	// BConfig is the default config for Application
 	Config *ConfigStorage
-
-	// configProvider is the provider for the config, default is ini
-	configProvider = "yml"
 )
 
 // 初始化 ratgo配置
 func init() {
 	Config = &ConfigStorage{
-		AppName:        "ratgo",
-		RunMode:        "dev",
-		AppPath:        "",
-		ConfigPath:     "",
-		RuntimePath:    "",
-		RuntimeLogPath: "",
-		HTTPAddr:       ":8080",
-		HTTPSAddr:      ":10443",
-		HTTPSCertFile:  "",
-		HTTPSKeyFile:   "",
-		DefinedConfig:  types.AnyMap{},
-		Pattern:        "debug",
-		StoreFormat:    1,
-		Storage:        "local",
-		ProjectName:    "lianxilog",
-		InitDb:         true,
-		InitRedis:      true,
-		HandleFunc:     nil,
+		AppName:         "ratgo",
+		RunMode:         "dev",
+		AppPath:         "",
+		ConfigPath:      "",
+		RuntimePath:     "",
+		RuntimeLogPath:  "",
+		EnableHTTP:      true,
+		HTTPAddr:        ":8080",
+		HTTPSAddr:       ":10443",
+		HTTPSCertFile:   "",
+		HTTPSKeyFile:    "",
+		ShutdownTimeout: 10,
+		RecoverPanic:    true,
+		WebsocketPath:   "/ws",
+		DefinedConfig:   types.AnyMap{},
+		Pattern:         "debug",
+		StoreFormat:     1,
+		Storage:         "local",
+		ProjectName:     "lianxilog",
+		InitDb:          true,
+		InitRedis:       true,
+		HandleFunc:      nil,
 	}
 }
 
@@ -106,33 +128,19 @@ func (cs *ConfigStorage) Init(outAnyMap ...map[string]interface{}) {
 		pathMap[key] = realpath
 	}
 
-	// Scan dir list & Load config
+	// 分层加载配置: 基础配置 < 运行模式配置 < 环境变量 < 命令行参数
+	cs.sources = map[string]ConfigSource{}
 	if outAnyMap != nil {
-		cs.DefinedConfig = outAnyMap[0]
-	} else {
-		configFiles := cs.ScanDir(pathMap["ConfigPath"])
-		for _, file := range configFiles {
-			fileName := file.Name()
-			filePath := filepath.Join(pathMap["ConfigPath"], fileName)
-			fileSplit := strings.Split(fileName, ".")
-			if fileSplit[1] == configProvider { // 配置类型过滤后缀名为 configProvider
-				if unitConfig, err := cs.ParserToAnyMap(configProvider, filePath); err != nil {
-					panic(err.Error())
-				} else {
-					cs.DefinedConfig[fileSplit[0]] = unitConfig
-				}
-			}
-		}
+		cs.definedMap = outAnyMap[0]
+	}
+	if cs.Error = cs.loadLayers(filepath.Dir(pathMap["ConfigPath"]), pathMap["ConfigPath"]); cs.Error != nil {
+		panic(cs.Error)
 	}
 
 	// App配置
-	appConfig := types.AnyMap(cs.Get("ratgo.main").ToAnyMap())
-	if len(appConfig) > 0 {
-		delete(cs.DefinedConfig, "ratgo")
-	}
+	appConfig := cs.splitAppConfig(pathMap)
 
 	// 更新服务配置
-	cs.MapMerge(appConfig, pathMap)
 	pt := reflect.TypeOf(cs).Elem()
 	pv := reflect.ValueOf(cs).Elem()
 	for i := 0; i < pt.NumField(); i++ {
@@ -162,56 +170,104 @@ func (cs *ConfigStorage) Init(outAnyMap ...map[string]interface{}) {
 	}
 }
 
+// 加载各层配置, 命令行配置参数格式错误时返回错误
+func (cs *ConfigStorage) loadLayers(baseConfigPath string, modeConfigPath string) error {
+	if cs.definedMap != nil {
+		cs.merge(cs.DefinedConfig, cs.definedMap, "", &ConfigSource{Layer: ConfigLayerDefined})
+	} else {
+		cs.loadDir(ConfigLayerBase, baseConfigPath)
+		cs.loadDir(ConfigLayerMode, modeConfigPath)
+	}
+	cs.loadEnv(os.Environ())
+	if err := cs.loadFlags(os.Args[1:]); err != nil {
+		return err
+	}
+	for _, set := range cs.runtimeSets {
+		cs.DefinedConfig.Set(set.key, set.value)
+		cs.setSource(set.key, &ConfigSource{Layer: ConfigLayerRuntime})
+	}
+	cs.resolveSecrets()
+	return nil
+}
+
+// 分离 ratgo 配置至 appConfig, 返回合并路径后的 ratgo.main 配置
+func (cs *ConfigStorage) splitAppConfig(pathMap map[string]string) types.AnyMap {
+	cs.appConfig = types.AnyMap(cs.DefinedConfig.Get("ratgo").ToAnyMap())
+	appConfig := types.AnyMap(cs.DefinedConfig.Get("ratgo.main").ToAnyMap())
+	if len(appConfig) > 0 {
+		delete(cs.DefinedConfig, "ratgo")
+	}
+	cs.MapMerge(appConfig, pathMap)
+	return appConfig
+}
+
 // 配置处理方法
 func (cs *ConfigStorage) Handle(fn func(config *ConfigStorage) error) {
 	cs.HandleFunc = fn
 }
 
-// 使用解析器解析文件至map类型配置数据
+// 使用解析器解析文件至map类型配置数据, configProvider 为文件扩展名(ini、json、yml、yaml、toml、hcl、env), 未注册时返回错误
 func (cs *ConfigStorage) ParserToAnyMap(configProvider string, filePath string) (map[string]interface{}, error) {
-	var configParser config.BaseParser
-	switch configProvider {
-	case "ini":
-		configParser = &config.IniParser{}
-	case "json":
-		configParser = &config.JsonParser{}
-	case "yml":
-		configParser = &config.YamlParser{}
-	default:
-		configParser = &config.IniParser{}
+	configParser, err := config.NewParser(configProvider)
+	if err != nil {
+		return nil, err
 	}
 	return configParser.ParserToMap(filePath)
 }
 
-// 合并map, 不支持递归合并
+// 注册配置解析器, 按文件扩展名加载对应格式配置, 需在 Init 前注册
+func (cs *ConfigStorage) RegisterParser(configProvider string, creator config.ParserCreator) {
+	config.Register(configProvider, creator)
+}
+
+// 递归合并map, 后者覆盖前者
 func (cs *ConfigStorage) MapMerge(dstMap map[string]interface{}, merged ...interface{}) {
-	if merged == nil {
-		return
-	}
 	for _, value := range merged {
-		switch value.(type) {
-		case map[string]interface{}:
-			for k, v := range value.(map[string]interface{}) {
-				dstMap[k] = v
-			}
-		case map[string]string:
-			for k, v := range value.(map[string]string) {
-				dstMap[k] = v
-			}
-		default:
-			continue
+		if srcMap, ok := toStringMap(value); ok {
+			cs.merge(dstMap, srcMap, "", nil)
 		}
 	}
 }
 
 // Get app config.
 func (cs *ConfigStorage) Get(args ...string) *types.AnyValue {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
 	return cs.DefinedConfig.Get(args...)
 }
 
+// 读取 ratgo 配置, 如 GetApp("cors.AllowOrigins")
+func (cs *ConfigStorage) GetApp(args ...string) *types.AnyValue {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.appConfig.Get(args...)
+}
+
+// 读取配置并解析到结构体, 如 Unmarshal("redis.master", &option), ratgo 配置使用 ratgo.xxx
+// 结构体标签: config(配置名) default(默认值) validate(required,min=N,max=N,oneof=a|b) unit(数值时长单位), 参考 types.Unmarshal
+// 校验失败时返回全部错误
+func (cs *ConfigStorage) Unmarshal(key string, out interface{}) error {
+	var value *types.AnyValue
+	if key == "ratgo" {
+		value = cs.GetApp()
+	} else if strings.HasPrefix(key, "ratgo.") {
+		value = cs.GetApp(strings.TrimPrefix(key, "ratgo."))
+	} else {
+		value = cs.Get(key)
+	}
+	if err := types.Unmarshal(value.Value(), out); err != nil {
+		return errors.New(fmt.Sprintf("unmarshal config '%s' failed. %v", key, err))
+	}
+	return nil
+}
+
 // Set value.
 func (cs *ConfigStorage) Set(args string, value interface{}) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
 	cs.DefinedConfig.Set(args, value)
+	cs.setSource(args, &ConfigSource{Layer: ConfigLayerRuntime})
+	cs.runtimeSets = append(cs.runtimeSets, runtimeSet{key: args, value: value})
 }
 
 // Judge dir is exist.
@@ -242,3 +298,257 @@ func (cs *ConfigStorage) ScanDir(dirPath string) (fileSlice []os.FileInfo) {
 	}
 	return fileSlice
 }
+
+/**************************************** 分层配置 ****************************************/
+// 配置层, 优先级由低到高
+const (
+	ConfigLayerBase    = "base"    // 基础配置 config/*
+	ConfigLayerMode    = "mode"    // 运行模式配置 config/<RunMode>/*
+	ConfigLayerDefined = "defined" // Init 传入的配置, 替代 base、mode
+	ConfigLayerEnv     = "env"     // 环境变量 RATGO__A__B=value
+	ConfigLayerFlag    = "flag"    // 命令行参数 --set a.b=value
+	ConfigLayerRuntime = "runtime" // 运行期间 Set
+)
+
+// 环境变量前缀, 层级使用 __ 分隔, 如 RATGO__REDIS__MASTER__HOST
+const configEnvPrefix = "RATGO__"
+
+// 配置目录下的 dotenv 文件, key 为完整配置路径, 如 redis.master.Host=127.0.0.1:6379
+const configDotEnvFile = ".env"
+
+// 配置来源
+type ConfigSource struct {
+	Layer string // 配置层
+	Name  string // 文件路径、环境变量名或命令行参数
+}
+
+// 获取配置项来源, 未记录时返回最近的上级配置来源, 如 Source("redis.master.Host")
+func (cs *ConfigStorage) Source(key string) (ConfigSource, bool) {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	for path := key; path != ""; {
+		if source, ok := cs.sources[path]; ok {
+			return source, true
+		}
+		index := strings.LastIndex(path, ".")
+		if index < 0 {
+			break
+		}
+		path = path[:index]
+	}
+	return ConfigSource{}, false
+}
+
+// 获取全部配置项来源, 可指定前缀过滤, 如 Sources("redis")
+func (cs *ConfigStorage) Sources(prefix ...string) map[string]ConfigSource {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	sources := map[string]ConfigSource{}
+	for path, source := range cs.sources {
+		if len(prefix) == 0 || path == prefix[0] || strings.HasPrefix(path, prefix[0]+".") {
+			sources[path] = source
+		}
+	}
+	return sources
+}
+
+// 加载目录下的配置文件, 文件名为一级配置key, .env 文件的key为完整配置路径
+func (cs *ConfigStorage) loadDir(layer string, dirPath string) {
+	for _, file := range configFiles(cs.ScanDir(dirPath)) {
+		fileName := file.Name()
+		filePath := filepath.Join(dirPath, fileName)
+		if unitConfig, err := cs.ParserToAnyMap(config.ProviderOf(fileName), filePath); err != nil {
+			panic(err.Error())
+		} else if fileName == configDotEnvFile {
+			cs.merge(cs.DefinedConfig, unitConfig, "", &ConfigSource{Layer: layer, Name: filePath})
+		} else {
+			cs.merge(cs.DefinedConfig, map[string]interface{}{strings.Split(fileName, ".")[0]: unitConfig}, "", &ConfigSource{Layer: layer, Name: filePath})
+		}
+	}
+}
+
+// 过滤已注册解析器的配置文件, 忽略 .env 外的隐藏文件, 按文件名排序(.env 最后), 同名不同格式的配置依次合并
+func configFiles(files []os.FileInfo) []os.FileInfo {
+	configFiles := make([]os.FileInfo, 0, len(files))
+	for _, file := range files {
+		fileName := file.Name()
+		if file.IsDir() || (strings.HasPrefix(fileName, ".") && fileName != configDotEnvFile) || !config.Supported(config.ProviderOf(fileName)) {
+			continue
+		}
+		configFiles = append(configFiles, file)
+	}
+	sort.Slice(configFiles, func(i, j int) bool {
+		if configFiles[i].Name() == configDotEnvFile || configFiles[j].Name() == configDotEnvFile { // .env 最后合并
+			return configFiles[j].Name() == configDotEnvFile && configFiles[i].Name() != configDotEnvFile
+		}
+		return configFiles[i].Name() < configFiles[j].Name()
+	})
+	return configFiles
+}
+
+// 加载环境变量, 层级不区分大小写, 优先匹配已有配置key
+func (cs *ConfigStorage) loadEnv(environ []string) {
+	sort.Strings(environ)
+	for _, env := range environ {
+		index := strings.Index(env, "=")
+		if index < 0 || !strings.HasPrefix(env[:index], configEnvPrefix) {
+			continue
+		}
+		name, value := env[:index], env[index+1:]
+		keys := strings.Split(strings.TrimPrefix(name, configEnvPrefix), "__")
+		cs.override(keys, value, true, ConfigSource{Layer: ConfigLayerEnv, Name: name})
+	}
+}
+
+// 加载命令行参数 --set a.b=value
+func (cs *ConfigStorage) loadFlags(args []string) error {
+	sets, _ := splitConfigFlags(args)
+	for _, set := range sets {
+		index := strings.Index(set, "=")
+		if index <= 0 {
+			return errors.New(fmt.Sprintf("invalid config flag '--set %s', expect --set key=value", set))
+		}
+		keys := strings.Split(set[:index], ".")
+		cs.override(keys, set[index+1:], false, ConfigSource{Layer: ConfigLayerFlag, Name: "--set " + set})
+	}
+	return nil
+}
+
+// 分离命令行中的配置参数 --set a.b=value, 返回配置参数及其余参数
+func splitConfigFlags(args []string) (sets []string, rest []string) {
+	rest = make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--set" || arg == "-set":
+			if i+1 < len(args) {
+				sets = append(sets, args[i+1])
+				i++
+			} else { // 缺少配置值, 由 loadFlags 报错
+				sets = append(sets, "")
+			}
+		case strings.HasPrefix(arg, "--set="):
+			sets = append(sets, strings.TrimPrefix(arg, "--set="))
+		case strings.HasPrefix(arg, "-set="):
+			sets = append(sets, strings.TrimPrefix(arg, "-set="))
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return sets, rest
+}
+
+// 覆盖配置项, 字符串值按原配置类型转换
+func (cs *ConfigStorage) override(keys []string, value string, ignoreCase bool, source ConfigSource) {
+	var current interface{} = map[string]interface{}(cs.DefinedConfig)
+	for i, key := range keys {
+		currentMap, _ := toStringMap(current)
+		if ignoreCase {
+			keys[i] = strings.ToLower(key)
+			for k := range currentMap {
+				if strings.EqualFold(k, key) {
+					keys[i] = k
+					break
+				}
+			}
+		}
+		current = currentMap[keys[i]]
+	}
+
+	// 构造嵌套map后合并
+	var nested interface{} = typedValue(value, current)
+	for i := len(keys) - 1; i >= 0; i-- {
+		nested = map[string]interface{}{keys[i]: nested}
+	}
+	cs.merge(cs.DefinedConfig, nested.(map[string]interface{}), "", &source)
+}
+
+// 递归合并, source 不为空时记录配置来源
+func (cs *ConfigStorage) merge(dstMap map[string]interface{}, srcMap map[string]interface{}, prefix string, source *ConfigSource) {
+	for key, value := range srcMap {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if valueMap, ok := toStringMap(value); ok {
+			subMap, ok := toStringMap(dstMap[key])
+			if !ok {
+				subMap = map[string]interface{}{}
+				if source != nil {
+					delete(cs.sources, path)
+				}
+			}
+			cs.merge(subMap, valueMap, path, source)
+			dstMap[key] = subMap
+			continue
+		}
+		dstMap[key] = value
+		if source != nil {
+			cs.setSource(path, source)
+		}
+	}
+}
+
+// 记录配置来源, 并清除下级配置来源
+func (cs *ConfigStorage) setSource(path string, source *ConfigSource) {
+	if cs.sources == nil {
+		cs.sources = map[string]ConfigSource{}
+	}
+	for key := range cs.sources {
+		if strings.HasPrefix(key, path+".") {
+			delete(cs.sources, key)
+		}
+	}
+	cs.sources[path] = *source
+}
+
+// 转换为可修改的 map[string]interface{}
+func toStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch value.(type) {
+	case map[string]interface{}:
+		return value.(map[string]interface{}), true
+	case types.AnyMap:
+		return value.(types.AnyMap), true
+	case map[string]string:
+		result := map[string]interface{}{}
+		for k, v := range value.(map[string]string) {
+			result[k] = v
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+// 环境变量、命令行参数值转换: 按原配置类型转换, 原配置不存在时识别 bool、int
+func typedValue(value string, current interface{}) interface{} {
+	switch current.(type) {
+	case string:
+		return value
+	case bool:
+		if v, err := strconv.ParseBool(value); err == nil {
+			return v
+		}
+	case int, int64:
+		if v, err := strconv.Atoi(value); err == nil {
+			return v
+		}
+	case float64:
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+	case []interface{}:
+		result := make([]interface{}, 0)
+		for _, v := range strings.Split(value, ",") {
+			result = append(result, strings.TrimSpace(v))
+		}
+		return result
+	case nil:
+		if value == "true" || value == "false" {
+			return value == "true"
+		}
+		if v, err := strconv.Atoi(value); err == nil && strconv.Itoa(v) == value {
+			return v
+		}
+	}
+	return value
+}
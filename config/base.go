@@ -1,6 +1,75 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
 type BaseParser interface {
 	Init() BaseParser
 	ParserToMap(filePath string) (anyMap map[string]interface{}, err error)
-}
\ No newline at end of file
+}
+
+// 解析器构造函数
+type ParserCreator func() BaseParser
+
+// 已注册的解析器, 扩展名 => 构造函数
+var (
+	parsers = map[string]ParserCreator{
+		"ini":  func() BaseParser { return &IniParser{} },
+		"json": func() BaseParser { return &JsonParser{} },
+		"yml":  func() BaseParser { return &YamlParser{} },
+		"yaml": func() BaseParser { return &YamlParser{} },
+		"toml": func() BaseParser { return &TomlParser{} },
+		"hcl":  func() BaseParser { return &HclParser{} },
+		"env":  func() BaseParser { return &EnvParser{} },
+	}
+	parsersLock sync.RWMutex
+)
+
+// 注册解析器, provider 为不含 . 的文件扩展名, 相同扩展名覆盖
+func Register(provider string, creator ParserCreator) {
+	parsersLock.Lock()
+	defer parsersLock.Unlock()
+	parsers[strings.ToLower(provider)] = creator
+}
+
+// 获取解析器, 未注册的扩展名返回错误
+func NewParser(provider string) (BaseParser, error) {
+	parsersLock.RLock()
+	creator, ok := parsers[strings.ToLower(provider)]
+	parsersLock.RUnlock()
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("config provider '%s' is not supported, registered: %s", provider, strings.Join(Providers(), ",")))
+	}
+	return creator(), nil
+}
+
+// 是否已注册解析器
+func Supported(provider string) bool {
+	parsersLock.RLock()
+	defer parsersLock.RUnlock()
+	_, ok := parsers[strings.ToLower(provider)]
+	return ok
+}
+
+// 已注册的扩展名
+func Providers() []string {
+	parsersLock.RLock()
+	defer parsersLock.RUnlock()
+	providers := make([]string, 0, len(parsers))
+	for provider := range parsers {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// 根据文件扩展名获取配置类型, 如 redis.toml => toml
+func ProviderOf(filePath string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(filePath), "."))
+}
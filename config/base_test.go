@@ -0,0 +1,84 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type testParser struct {
+	BaseParser
+}
+
+func (tp *testParser) Init() BaseParser {
+	return tp
+}
+
+func (tp *testParser) ParserToMap(filePath string) (map[string]interface{}, error) {
+	return map[string]interface{}{"file": filepath.Base(filePath)}, nil
+}
+
+func TestRegister_01(t *testing.T) {
+	if _, err := NewParser("xml"); err == nil || Supported("xml") {
+		t.FailNow()
+	}
+	Register("XML", func() BaseParser { return &testParser{} })
+	defer func() {
+		parsersLock.Lock()
+		delete(parsers, "xml")
+		parsersLock.Unlock()
+	}()
+	parser, err := NewParser("xml")
+	if err != nil || !Supported("Xml") {
+		t.Fatal(err)
+	}
+	if anyMap, _ := parser.ParserToMap("/app/config/redis.xml"); anyMap["file"] != "redis.xml" {
+		t.Fatal(anyMap)
+	}
+	for filePath, provider := range map[string]string{"redis.TOML": "toml", "a/b.c/.env": "env", "redis": ""} {
+		if ProviderOf(filePath) != provider {
+			t.Errorf("'%s' expect '%s'", filePath, provider)
+		}
+	}
+}
+
+func TestParser_01(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ratgo_parser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"redis.toml": "[master]\nHost = \"127.0.0.1:6379\"\nDb = 2\n",
+		"redis.hcl":  "master {\n  Host = \"127.0.0.1:6379\"\n  Db = 2\n}\n",
+		"redis.env":  "master.Host=127.0.0.1:6379\nmaster__Db=2\n",
+	}
+	expects := map[string]interface{}{
+		"toml": map[string]interface{}{"master": map[string]interface{}{"Host": "127.0.0.1:6379", "Db": 2}},
+		"hcl":  map[string]interface{}{"master": map[string]interface{}{"Host": "127.0.0.1:6379", "Db": 2}},
+		"env":  map[string]interface{}{"master": map[string]interface{}{"Host": "127.0.0.1:6379", "Db": "2"}},
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		parser, _ := NewParser(ProviderOf(name))
+		anyMap, err := parser.ParserToMap(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(anyMap, expects[ProviderOf(name)]) {
+			t.Errorf("'%s' receive %v", name, anyMap)
+		}
+	}
+
+	// env key 冲突
+	filePath := filepath.Join(dir, "conflict.env")
+	_ = ioutil.WriteFile(filePath, []byte("master=1\nmaster.Db=2\n"), 0644)
+	if _, err := (&EnvParser{}).ParserToMap(filePath); err == nil {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"sort"
+	"strings"
+)
+
+type EnvParser struct {
+	BaseParser
+}
+
+func (ep *EnvParser) Init() BaseParser {
+	return ep
+}
+
+// 解析文件, key 使用 . 或 __ 分隔层级, 如 master.Host=127.0.0.1 或 master__Host=127.0.0.1, 值均为字符串
+func (ep *EnvParser) ParserToMap(filePath string) (map[string]interface{}, error) {
+	envMap, err := godotenv.Read(filePath)
+	if err != nil {
+		errorMsg := fmt.Sprintf("decode config file '"+filePath+"' failed: %s", err.Error())
+		return nil, errors.New(errorMsg)
+	}
+
+	// 按层级存储, 排序保证冲突时报错信息一致
+	keys := make([]string, 0, len(envMap))
+	for key := range envMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	anyMap := make(map[string]interface{})
+	for _, key := range keys {
+		if err := ep.set(anyMap, strings.Split(strings.ReplaceAll(key, "__", "."), "."), envMap[key]); err != nil {
+			errorMsg := fmt.Sprintf("decode config file '"+filePath+"' failed: key '%s' %s", key, err.Error())
+			return nil, errors.New(errorMsg)
+		}
+	}
+	return anyMap, nil
+}
+
+// 设置层级值
+func (ep *EnvParser) set(anyMap map[string]interface{}, keys []string, value string) error {
+	for i, key := range keys {
+		if key == "" {
+			return errors.New("has empty level")
+		}
+		if i == len(keys)-1 {
+			if _, ok := anyMap[key].(map[string]interface{}); ok {
+				return errors.New("conflicts with its sub keys")
+			}
+			anyMap[key] = value
+			return nil
+		}
+		child, ok := anyMap[key]
+		if !ok {
+			child = map[string]interface{}{}
+			anyMap[key] = child
+		}
+		if anyMap, ok = child.(map[string]interface{}); !ok {
+			return errors.New("conflicts with a value key")
+		}
+	}
+	return nil
+}
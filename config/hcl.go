@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/hcl"
+	"io/ioutil"
+)
+
+type HclParser struct {
+	BaseParser
+}
+
+func (hp *HclParser) Init() BaseParser {
+	return hp
+}
+
+// 解析文件
+func (hp *HclParser) ParserToMap(filePath string) (map[string]interface{}, error) {
+	// 从配置文件中读取hcl字符串
+	buf, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		errorMsg := fmt.Sprintf("load config conf file '"+filePath+"'failed: %s", err.Error())
+		return nil, errors.New(errorMsg)
+	}
+
+	// 存储map
+	anyMap := make(map[string]interface{})
+	err = hcl.Unmarshal(buf, &anyMap)
+	if err != nil {
+		errorMsg := fmt.Sprintf("decode config file '"+filePath+"' failed: %s", err.Error())
+		return nil, errors.New(errorMsg)
+	}
+	return hp.stdMap(anyMap), nil
+}
+
+// hcl 块解析为 []map[string]interface{}, 合并为 map, 如 master { Host = "" } => {master: {Host: ""}}
+func (hp *HclParser) stdMap(anyMap map[string]interface{}) map[string]interface{} {
+	for key, value := range anyMap {
+		anyMap[key] = hp.stdValue(value)
+	}
+	return anyMap
+}
+
+// 合并块
+func (hp *HclParser) stdValue(value interface{}) interface{} {
+	switch value.(type) {
+	case map[string]interface{}:
+		return hp.stdMap(value.(map[string]interface{}))
+	case []map[string]interface{}:
+		merged := map[string]interface{}{}
+		for _, item := range value.([]map[string]interface{}) {
+			for k, v := range hp.stdMap(item) {
+				if dst, ok := merged[k].(map[string]interface{}); ok {
+					if src, ok := v.(map[string]interface{}); ok {
+						for sk, sv := range src {
+							dst[sk] = sv
+						}
+						continue
+					}
+				}
+				merged[k] = v
+			}
+		}
+		return merged
+	case []interface{}:
+		slice := value.([]interface{})
+		for i, item := range slice {
+			slice[i] = hp.stdValue(item)
+		}
+		return slice
+	}
+	return value
+}
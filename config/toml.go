@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+)
+
+type TomlParser struct {
+	BaseParser
+}
+
+func (tp *TomlParser) Init() BaseParser {
+	return tp
+}
+
+// 解析文件
+func (tp *TomlParser) ParserToMap(filePath string) (map[string]interface{}, error) {
+	// 从配置文件中读取toml字符串
+	buf, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		errorMsg := fmt.Sprintf("load config conf file '"+filePath+"'failed: %s", err.Error())
+		return nil, errors.New(errorMsg)
+	}
+
+	// 存储map
+	anyMap := make(map[string]interface{})
+	_, err = toml.Decode(string(buf), &anyMap)
+	if err != nil {
+		errorMsg := fmt.Sprintf("decode config file '"+filePath+"' failed: %s", err.Error())
+		return nil, errors.New(errorMsg)
+	}
+	return tp.stdMap(anyMap), nil
+}
+
+// 转换为与 yml、json 一致的类型: int64 转 int, 表数组转 []interface{}
+func (tp *TomlParser) stdMap(anyMap map[string]interface{}) map[string]interface{} {
+	for key, value := range anyMap {
+		anyMap[key] = tp.stdValue(value)
+	}
+	return anyMap
+}
+
+// 转换值类型
+func (tp *TomlParser) stdValue(value interface{}) interface{} {
+	switch value.(type) {
+	case int64:
+		return int(value.(int64))
+	case map[string]interface{}:
+		return tp.stdMap(value.(map[string]interface{}))
+	case []map[string]interface{}:
+		slice := make([]interface{}, len(value.([]map[string]interface{})))
+		for i, item := range value.([]map[string]interface{}) {
+			slice[i] = tp.stdMap(item)
+		}
+		return slice
+	case []interface{}:
+		slice := value.([]interface{})
+		for i, item := range slice {
+			slice[i] = tp.stdValue(item)
+		}
+		return slice
+	}
+	return value
+}
@@ -0,0 +1,154 @@
+package ratgo
+
+import (
+	"github.com/vdongchina/ratgo/utils/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 创建临时应用目录并切换工作目录, 返回配置存储及清理函数
+func testConfigStorage(t *testing.T, files map[string]string) (*ConfigStorage, func()) {
+	appPath, err := ioutil.TempDir("", "ratgo_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		testWriteFile(t, filepath.Join(appPath, name), content)
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(appPath); err != nil {
+		t.Fatal(err)
+	}
+	cs := &ConfigStorage{DefinedConfig: types.AnyMap{}}
+	return cs, func() {
+		_ = os.Chdir(wd)
+		_ = os.RemoveAll(appPath)
+	}
+}
+
+// 写入文件, 自动创建目录
+func testWriteFile(t *testing.T, filename string, content string) {
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigLayer_01(t *testing.T) {
+	cs, cleanup := testConfigStorage(t, map[string]string{
+		"config/redis.yml":     "master:\n  Host: 127.0.0.1:6379\n  Db: 1\n  MaxIdle: 16\n",
+		"config/dev/redis.yml": "master:\n  Db: 2\n",
+		"config/dev/app.toml":  "[cache]\nttl = 60\n",
+		"config/dev/.env":      "app.cache.prefix=ratgo_\n",
+		"config/dev/.hidden":   "ignored",
+	})
+	defer cleanup()
+	_ = os.Setenv("RATGO__REDIS__MASTER__HOST", "10.0.0.1:6379")
+	defer os.Unsetenv("RATGO__REDIS__MASTER__HOST")
+	cs.Init()
+
+	if host := cs.Get("redis.master.Host").ToString(); host != "10.0.0.1:6379" {
+		t.Fatal(host)
+	}
+	if db := cs.Get("redis.master.Db").ToInt(); db != 2 {
+		t.Fatal(db)
+	}
+	if ttl := cs.Get("app.cache.ttl").ToInt(); ttl != 60 || cs.Get("app.cache.prefix").ToString() != "ratgo_" {
+		t.Fatal(cs.Get("app").Value())
+	}
+	if source, _ := cs.Source("redis.master.Db"); source.Layer != ConfigLayerMode {
+		t.Fatal(source)
+	}
+	if source, _ := cs.Source("redis.master.Host"); source.Layer != ConfigLayerEnv {
+		t.Fatal(source)
+	}
+
+	// 命令行参数按原配置类型转换
+	if err := cs.loadFlags([]string{"serve", "--set", "redis.master.MaxIdle=32", "--set=redis.master.Password=x"}); err != nil {
+		t.Fatal(err)
+	}
+	if maxIdle, ok := cs.Get("redis.master.MaxIdle").Value().(int); !ok || maxIdle != 32 {
+		t.Fatal(cs.Get("redis.master.MaxIdle").Value())
+	}
+	if source, _ := cs.Source("redis.master.Password"); source.Layer != ConfigLayerFlag {
+		t.Fatal(source)
+	}
+	for _, args := range [][]string{{"--set", "redis.master.Db"}, {"--set"}, {"--set==1"}} {
+		if err := cs.loadFlags(args); err == nil || !strings.Contains(err.Error(), "expect --set key=value") {
+			t.Errorf("%v receive %v", args, err)
+		}
+	}
+}
+
+func TestConfigReload_01(t *testing.T) {
+	cs, cleanup := testConfigStorage(t, map[string]string{
+		"config/redis.yml":    "master:\n  Host: 127.0.0.1:6379\n",
+		"config/database.yml": "master:\n  DriverName: mysql\n",
+	})
+	defer cleanup()
+	cs.Init()
+	cs.Set("database.master.DSN", "kept")
+
+	notified := map[string]interface{}{}
+	cs.Subscribe("redis.master", func(key string, value *types.AnyValue) error {
+		notified[key] = value.ToAnyMap()["Host"]
+		return nil
+	})
+	cs.Subscribe("database", func(key string, value *types.AnyValue) error {
+		notified[key] = value.Value()
+		return nil
+	})
+
+	// 仅通知变更的订阅者, 运行期间 Set 的配置保留
+	testWriteFile(t, "config/redis.yml", "master:\n  Host: 10.0.0.1:6379\n")
+	if err := cs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(notified) != 1 || notified["redis.master"] != "10.0.0.1:6379" {
+		t.Fatal(notified)
+	}
+	if cs.Get("redis.master.Host").ToString() != "10.0.0.1:6379" || cs.Get("database.master.DSN").ToString() != "kept" {
+		t.Fatal(cs.Get("redis").Value(), cs.Get("database").Value())
+	}
+
+	// 加载失败时保留原配置
+	testWriteFile(t, "config/redis.yml", "master: [broken")
+	if err := cs.Reload(); err == nil {
+		t.FailNow()
+	}
+	if cs.Get("redis.master.Host").ToString() != "10.0.0.1:6379" || len(notified) != 1 {
+		t.Fatal(cs.Get("redis").Value(), notified)
+	}
+}
+
+func TestConfigWatch_01(t *testing.T) {
+	cs, cleanup := testConfigStorage(t, map[string]string{
+		"config/redis.yml": "master:\n  Host: 127.0.0.1:6379\n",
+	})
+	defer cleanup()
+	cs.Init()
+
+	// 间隔不合法时不监听
+	cs.Watch(0)
+	cs.Watch(-time.Second)
+	if cs.watchStop != nil {
+		t.FailNow()
+	}
+
+	// 热加载配置的间隔最小为1秒
+	defer func(config *ConfigStorage) { Config = config }(Config)
+	Config = cs
+	Config.InitDb, Config.InitRedis = false, false
+	cs.appConfig = types.AnyMap{"reload": map[string]interface{}{"Enable": true, "Interval": 0}}
+	initReload("test")
+	defer cs.StopWatch()
+	if cs.watchStop == nil {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// 服务作用域
+const (
+	ScopeSingleton = "singleton" // 单例, 首次获取时创建
+	ScopeTransient = "transient" // 每次获取均创建
+	ScopeRequest   = "request"   // 每个请求创建一次, 存储于 gin.Context
+)
+
+// 注入标签, 例: `inject:""` 按类型注入, `inject:"logger"` 按名称注入
+const injectTag = "inject"
+
+// 前缀服务提供者, 例: db.plus_center.master 由 db 提供者根据 plus_center.master 创建
+type Provider func(key string) (interface{}, error)
+
+// 服务定义
+type serviceDefine struct {
+	name     string
+	scope    string
+	typ      reflect.Type  // 服务类型
+	factory  reflect.Value // 工厂函数: func(依赖...) T 或 func(依赖...) (T, error)
+	lock     sync.Mutex
+	created  bool
+	instance interface{}
+}
+
+// 服务容器
+type ContainerStorage struct {
+	lock      sync.RWMutex
+	services  map[string]*serviceDefine
+	providers map[string]Provider
+}
+
+// 应用容器
+var AppStorage *ContainerStorage
+
+// 类型
+var (
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	contextType   = reflect.TypeOf((*gin.Context)(nil))
+	containerType = reflect.TypeOf((*ContainerStorage)(nil))
+)
+
+// 获取服务容器
+func NewContainer() *ContainerStorage {
+	return &ContainerStorage{
+		services:  map[string]*serviceDefine{},
+		providers: map[string]Provider{},
+	}
+}
+
+// 注册单例实例
+func (cs *ContainerStorage) Set(name string, instance interface{}) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.services[name] = &serviceDefine{
+		name:     name,
+		scope:    ScopeSingleton,
+		typ:      reflect.TypeOf(instance),
+		created:  true,
+		instance: instance,
+	}
+}
+
+// 注册单例服务
+func (cs *ContainerStorage) Singleton(name string, factory interface{}) error {
+	return cs.register(name, ScopeSingleton, factory)
+}
+
+// 注册瞬态服务
+func (cs *ContainerStorage) Transient(name string, factory interface{}) error {
+	return cs.register(name, ScopeTransient, factory)
+}
+
+// 注册请求作用域服务
+func (cs *ContainerStorage) Request(name string, factory interface{}) error {
+	return cs.register(name, ScopeRequest, factory)
+}
+
+// 注册前缀服务提供者
+func (cs *ContainerStorage) Provider(prefix string, provider Provider) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.providers[prefix] = provider
+}
+
+// 是否存在服务
+func (cs *ContainerStorage) Has(name string) bool {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	_, ok := cs.services[name]
+	return ok
+}
+
+// 获取服务(不含请求作用域), 获取失败返回 nil
+func (cs *ContainerStorage) Get(name string) interface{} {
+	instance, err := cs.Resolve(name, nil)
+	if err != nil {
+		return nil
+	}
+	return instance
+}
+
+// 根据名称获取服务, ctx为空时无法获取请求作用域服务
+func (cs *ContainerStorage) Resolve(name string, ctx *gin.Context) (interface{}, error) {
+	return cs.resolveName(name, ctx, map[string]bool{})
+}
+
+// 根据类型获取服务
+func (cs *ContainerStorage) ResolveType(typ reflect.Type, ctx *gin.Context) (interface{}, error) {
+	return cs.resolveType(typ, ctx, map[string]bool{})
+}
+
+// 调用函数, 参数根据类型从容器获取
+func (cs *ContainerStorage) Invoke(fn interface{}, ctx *gin.Context) ([]reflect.Value, error) {
+	fnValue := reflect.ValueOf(fn)
+	if fnValue.Kind() != reflect.Func {
+		return nil, errors.New("invoke target must be a func")
+	}
+	args, err := cs.arguments(fnValue.Type(), ctx, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return fnValue.Call(args), nil
+}
+
+// 注入结构体字段, 仅处理带 inject 标签的可导出字段(含匿名嵌套结构体)
+func (cs *ContainerStorage) Inject(obj interface{}, ctx *gin.Context) error {
+	value := reflect.ValueOf(obj)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return errors.New("inject target must be a pointer to struct")
+	}
+	return cs.injectStruct(value.Elem(), ctx)
+}
+
+// 注入结构体
+func (cs *ContainerStorage) injectStruct(value reflect.Value, ctx *gin.Context) error {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field, fieldValue := typ.Field(i), value.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := cs.injectStruct(fieldValue, ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		name, ok := field.Tag.Lookup(injectTag)
+		if !ok || !fieldValue.CanSet() {
+			continue
+		}
+		var instance interface{}
+		var err error
+		if name = strings.TrimSpace(name); name != "" {
+			instance, err = cs.Resolve(name, ctx)
+		} else {
+			instance, err = cs.ResolveType(field.Type, ctx)
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("inject field '%s.%s' failed. error:%v", typ.Name(), field.Name, err))
+		}
+		instanceValue := reflect.ValueOf(instance)
+		if !instanceValue.IsValid() {
+			continue
+		}
+		if !instanceValue.Type().AssignableTo(field.Type) {
+			return errors.New(fmt.Sprintf("inject field '%s.%s' failed. %s is not assignable to %s", typ.Name(), field.Name, instanceValue.Type(), field.Type))
+		}
+		fieldValue.Set(instanceValue)
+	}
+	return nil
+}
+
+// 注册服务
+func (cs *ContainerStorage) register(name string, scope string, factory interface{}) error {
+	factoryValue := reflect.ValueOf(factory)
+	factoryType := factoryValue.Type()
+	if factoryType.Kind() != reflect.Func {
+		return errors.New(fmt.Sprintf("the factory of service '%s' must be a func", name))
+	}
+	if numOut := factoryType.NumOut(); numOut == 0 || numOut > 2 || (numOut == 2 && factoryType.Out(1) != errorType) {
+		return errors.New(fmt.Sprintf("the factory of service '%s' must return (T) or (T, error)", name))
+	}
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.services[name] = &serviceDefine{
+		name:    name,
+		scope:   scope,
+		typ:     factoryType.Out(0),
+		factory: factoryValue,
+	}
+	return nil
+}
+
+// 根据名称获取服务
+func (cs *ContainerStorage) resolveName(name string, ctx *gin.Context, resolving map[string]bool) (interface{}, error) {
+	cs.lock.RLock()
+	define, ok := cs.services[name]
+	cs.lock.RUnlock()
+	if !ok {
+		return cs.provide(name)
+	}
+	return cs.instance(define, ctx, resolving)
+}
+
+// 使用前缀服务提供者获取服务
+func (cs *ContainerStorage) provide(name string) (interface{}, error) {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	if index := strings.Index(name, "."); index > 0 {
+		if provider, ok := cs.providers[name[:index]]; ok {
+			return provider(name[index+1:])
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("service '%s' is not registered", name))
+}
+
+// 根据类型获取服务, 接口类型匹配唯一实现
+func (cs *ContainerStorage) resolveType(typ reflect.Type, ctx *gin.Context, resolving map[string]bool) (interface{}, error) {
+	switch typ {
+	case contextType: // 请求外为 nil
+		return ctx, nil
+	case containerType:
+		return cs, nil
+	}
+	cs.lock.RLock()
+	var matched []*serviceDefine
+	for _, define := range cs.services {
+		if define.typ == typ {
+			matched = []*serviceDefine{define}
+			break
+		}
+		if typ.Kind() == reflect.Interface && define.typ != nil && define.typ.Implements(typ) {
+			matched = append(matched, define)
+		}
+	}
+	cs.lock.RUnlock()
+	switch len(matched) {
+	case 0:
+		return nil, errors.New(fmt.Sprintf("service of type '%s' is not registered", typ))
+	case 1:
+		return cs.instance(matched[0], ctx, resolving)
+	default:
+		return nil, errors.New(fmt.Sprintf("service of type '%s' is ambiguous", typ))
+	}
+}
+
+// 获取服务实例
+func (cs *ContainerStorage) instance(define *serviceDefine, ctx *gin.Context, resolving map[string]bool) (interface{}, error) {
+	if resolving[define.name] {
+		return nil, errors.New(fmt.Sprintf("service '%s' has circular dependency", define.name))
+	}
+	resolving[define.name] = true
+	defer delete(resolving, define.name)
+
+	switch define.scope {
+	case ScopeSingleton:
+		define.lock.Lock()
+		defer define.lock.Unlock()
+		if !define.created {
+			instance, err := cs.create(define, ctx, resolving)
+			if err != nil {
+				return nil, err
+			}
+			define.instance, define.created = instance, true
+		}
+		return define.instance, nil
+	case ScopeRequest:
+		if ctx == nil {
+			return nil, errors.New(fmt.Sprintf("request service '%s' is not available outside request", define.name))
+		}
+		key := "ratgo.container." + define.name
+		if instance, ok := ctx.Get(key); ok {
+			return instance, nil
+		}
+		instance, err := cs.create(define, ctx, resolving)
+		if err != nil {
+			return nil, err
+		}
+		ctx.Set(key, instance)
+		return instance, nil
+	default:
+		return cs.create(define, ctx, resolving)
+	}
+}
+
+// 调用工厂函数创建实例
+func (cs *ContainerStorage) create(define *serviceDefine, ctx *gin.Context, resolving map[string]bool) (interface{}, error) {
+	args, err := cs.arguments(define.factory.Type(), ctx, resolving)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("create service '%s' failed. error:%v", define.name, err))
+	}
+	out := define.factory.Call(args)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	return out[0].Interface(), nil
+}
+
+// 根据参数类型获取函数参数
+func (cs *ContainerStorage) arguments(fnType reflect.Type, ctx *gin.Context, resolving map[string]bool) ([]reflect.Value, error) {
+	args := make([]reflect.Value, fnType.NumIn())
+	for i := 0; i < fnType.NumIn(); i++ {
+		argType := fnType.In(i)
+		instance, err := cs.resolveType(argType, ctx, resolving)
+		if err != nil {
+			return nil, err
+		}
+		if instance == nil {
+			args[i] = reflect.Zero(argType)
+		} else {
+			args[i] = reflect.ValueOf(instance)
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,175 @@
+package ratgo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testRepo struct {
+	id int
+}
+
+func (tr *testRepo) Name() string {
+	return fmt.Sprint("repo", tr.id)
+}
+
+type testNamer interface {
+	Name() string
+}
+
+type testCycleA struct{}
+type testCycleB struct{}
+
+func TestContainerScope_01(t *testing.T) {
+	container := NewContainer()
+	count := 0
+	newRepo := func() *testRepo {
+		count++
+		return &testRepo{id: count}
+	}
+
+	// 单例仅创建一次
+	_ = container.Singleton("repo", newRepo)
+	first, _ := container.Resolve("repo", nil)
+	second := container.Get("repo")
+	if first != second || count != 1 {
+		t.Fatal(first, second, count)
+	}
+
+	// 瞬态每次创建
+	_ = container.Transient("transient", newRepo)
+	if a, b := container.Get("transient"), container.Get("transient"); a == b {
+		t.Fatal(a, b)
+	}
+
+	// 请求作用域: 同一请求复用, 请求外不可获取
+	_ = container.Request("request", newRepo)
+	if _, err := container.Resolve("request", nil); err == nil || container.Get("request") != nil {
+		t.FailNow()
+	}
+	ctx1, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx2, _ := gin.CreateTestContext(httptest.NewRecorder())
+	a, _ := container.Resolve("request", ctx1)
+	b, _ := container.Resolve("request", ctx1)
+	c, _ := container.Resolve("request", ctx2)
+	if a != b || a == c {
+		t.Fatal(a, b, c)
+	}
+
+	// 注册实例
+	repo := &testRepo{id: 100}
+	container.Set("instance", repo)
+	if !container.Has("instance") || container.Get("instance") != repo || container.Has("missing") {
+		t.FailNow()
+	}
+}
+
+func TestContainerError_01(t *testing.T) {
+	container := NewContainer()
+	if err := container.Singleton("value", 1); err == nil {
+		t.FailNow()
+	}
+	if err := container.Singleton("noReturn", func() {}); err == nil {
+		t.FailNow()
+	}
+	if err := container.Singleton("badReturn", func() (*testRepo, int) { return nil, 0 }); err == nil {
+		t.FailNow()
+	}
+	_ = container.Singleton("failed", func() (*testRepo, error) { return nil, errors.New("connect refused") })
+	if _, err := container.Resolve("failed", nil); err == nil || err.Error() != "connect refused" {
+		t.Fatal(err)
+	}
+	if _, err := container.Resolve("missing", nil); err == nil {
+		t.FailNow()
+	}
+
+	// 循环依赖
+	_ = container.Singleton("a", func(b *testCycleB) *testCycleA { return &testCycleA{} })
+	_ = container.Singleton("b", func(a *testCycleA) *testCycleB { return &testCycleB{} })
+	if _, err := container.Resolve("a", nil); err == nil || !strings.Contains(err.Error(), "circular dependency") {
+		t.Fatal(err)
+	}
+
+	// 接口类型匹配多个实现
+	_ = container.Singleton("repo1", func() *testRepo { return &testRepo{id: 1} })
+	_ = container.Singleton("namer", func() testNamer { return &testRepo{id: 2} })
+	if _, err := container.ResolveType(reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), nil); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Fatal(err)
+	}
+	other := NewContainer()
+	_ = other.Singleton("repo1", func() *testRepo { return &testRepo{id: 1} })
+	_ = other.Singleton("repo2", func() *testRepo { return &testRepo{id: 2} })
+	if _, err := other.ResolveType(reflect.TypeOf((*testNamer)(nil)).Elem(), nil); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatal(err)
+	}
+}
+
+func TestContainerInject_01(t *testing.T) {
+	container := NewContainer()
+	_ = container.Singleton("repo", func() *testRepo { return &testRepo{id: 1} })
+	_ = container.Transient("label", func(repo *testRepo, ctx *gin.Context) string {
+		return repo.Name() + ":" + ctx.Query("lang")
+	})
+	container.Provider("db", func(key string) (interface{}, error) {
+		if key == "missing" {
+			return nil, errors.New("db 'missing' is not configured")
+		}
+		return "db:" + key, nil
+	})
+	container.Provider("dbx", func(key string) (interface{}, error) {
+		return "dbx:" + key, nil
+	})
+
+	type base struct {
+		Repo *testRepo `inject:""`
+	}
+	type target struct {
+		base
+		Namer   testNamer `inject:""`
+		Label   string    `inject:"label"`
+		DB      string    `inject:"db.plus_center.master"`
+		DBX     string    `inject:"dbx.plus_center"`
+		Skipped *testRepo
+		private *testRepo `inject:""`
+	}
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest("GET", "/?lang=zh", nil)
+	obj := &target{}
+	if err := container.Inject(obj, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if obj.Repo == nil || obj.Namer != obj.Repo || obj.Label != "repo1:zh" || obj.Skipped != nil || obj.private != nil {
+		t.Fatal(obj)
+	}
+
+	// 前缀提供者按第一个 . 前的名称匹配
+	if obj.DB != "db:plus_center.master" || obj.DBX != "dbx:plus_center" {
+		t.Fatal(obj.DB, obj.DBX)
+	}
+	for _, name := range []string{"db", "cache.master", "db.missing"} {
+		if _, err := container.Resolve(name, nil); err == nil {
+			t.Errorf("'%s' expect error", name)
+		}
+	}
+
+	// 注入失败
+	if err := container.Inject(target{}, ctx); err == nil {
+		t.FailNow()
+	}
+	if err := container.Inject(&struct {
+		Repo int `inject:"repo"`
+	}{}, ctx); err == nil || !strings.Contains(err.Error(), "not assignable") {
+		t.Fatal(err)
+	}
+
+	// Invoke
+	out, err := container.Invoke(func(repo *testRepo, c *ContainerStorage) string { return repo.Name() + fmt.Sprint(c == container) }, nil)
+	if err != nil || out[0].String() != "repo1true" {
+		t.Fatal(out, err)
+	}
+}
@@ -9,7 +9,15 @@
 // limitations under the License.
 package ratgo
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+)
 
 // 控制器接口
 type ControllerInterface interface {
@@ -17,7 +25,14 @@ type ControllerInterface interface {
 	Context() *gin.Context                 // 获取gin的 Context
 	BeforeExec()                           // 动作前执行方法
 	Exec()                                 // 动作方法
-	Result() *Result                       // 控制响应(辅助用法,阻断执行直接响应异常)
+	AfterExec()                            // 动作后执行方法(Exec 未 panic 时执行)
+	Finally()                              // 最终执行方法(无论是否阻断或 panic 均执行)
+	Result() *Result                       // 控制响应(执行结束后渲染, 控制器已直接写入响应或调用 context.Status 时跳过)
+}
+
+// 声明允许的请求方法(可选), 简易模式下其他方法响应 405, 未实现时使用 DefaultAllowMethods
+type AllowMethodsInterface interface {
+	AllowMethods() []string
 }
 
 // 控制器
@@ -37,6 +52,27 @@ func (c *Controller) Context() *gin.Context {
 	return c.context
 }
 
+// 获取认证主体, 未认证时返回 nil
+func (c *Controller) Principal() *Principal {
+	principal, _ := GetPrincipal(c.context)
+	return principal
+}
+
+// 获取链路追踪 context, 用于 Dao、RedisDao、curl 的 WithContext
+func (c *Controller) TraceContext() context.Context {
+	return c.context.Request.Context()
+}
+
+// 获取 session, 需开启 session 中间件
+func (c *Controller) Session() *Session {
+	return GetSession(c.context)
+}
+
+// 获取路径参数(Restful模式下的 :name、*name)
+func (c *Controller) Param(name string) string {
+	return c.context.Param(name)
+}
+
 // 简易模式 - 前置方法
 func (c *Controller) BeforeExec() {
 
@@ -47,7 +83,103 @@ func (c *Controller) Exec() {
 
 }
 
+// 简易模式 - 后置方法
+func (c *Controller) AfterExec() {
+
+}
+
+// 简易模式 - 最终方法
+func (c *Controller) Finally() {
+
+}
+
 // 结果方法
 func (c *Controller) Result() *Result {
 	return c.result
 }
+
+// 绑定参数, 根据请求方法及 Content-Type 选择解析方式
+func (c *Controller) Bind(obj interface{}) error {
+	return c.bindWith(obj, binding.Default(c.context.Request.Method, c.context.ContentType()))
+}
+
+// 绑定 query 参数, 字段标签 form:"name"
+func (c *Controller) BindQuery(obj interface{}) error {
+	return c.bindWith(obj, binding.Query)
+}
+
+// 绑定 form 参数(含 query), 字段标签 form:"name"
+func (c *Controller) BindForm(obj interface{}) error {
+	return c.bindWith(obj, binding.Form)
+}
+
+// 绑定 json body, 字段标签 json:"name"
+func (c *Controller) BindJSON(obj interface{}) error {
+	return c.bindWith(obj, binding.JSON)
+}
+
+// 绑定 xml body, 字段标签 xml:"name"
+func (c *Controller) BindXML(obj interface{}) error {
+	return c.bindWith(obj, binding.XML)
+}
+
+// 绑定路径参数, 字段标签 uri:"param_1"(简易模式) 或 uri:"id"(Restful模式)
+func (c *Controller) BindParam(obj interface{}) error {
+	params := make(map[string][]string)
+	for _, v := range c.context.Params {
+		params[v.Key] = []string{v.Value}
+	}
+	if err := binding.Uri.BindUri(params, obj); err != nil {
+		return c.bindFailed(err)
+	}
+	return nil
+}
+
+// 使用指定解析方式绑定参数, 校验规则使用字段标签 binding:"required,max=10"
+func (c *Controller) bindWith(obj interface{}, b binding.Binding) error {
+	if err := c.context.ShouldBindWith(obj, b); err != nil {
+		return c.bindFailed(err)
+	}
+	return nil
+}
+
+// 参数绑定失败, 设置400响应及字段错误信息
+func (c *Controller) bindFailed(err error) error {
+	fields := map[string]string{}
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		for _, fieldError := range validationErrors {
+			fields[fieldError.Field()] = validationMessage(fieldError)
+		}
+		c.result.SetStatus(http.StatusBadRequest).SetMsg("参数校验失败").SetData(fields)
+	} else {
+		c.result.SetStatus(http.StatusBadRequest).SetMsg(fmt.Sprintf("参数解析失败: %v", err)).SetData(fields)
+	}
+	return err
+}
+
+// 校验错误提示
+func validationMessage(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "不能为空"
+	case "min", "gte":
+		return fmt.Sprintf("不能小于 %s", fieldError.Param())
+	case "max", "lte":
+		return fmt.Sprintf("不能大于 %s", fieldError.Param())
+	case "gt":
+		return fmt.Sprintf("必须大于 %s", fieldError.Param())
+	case "lt":
+		return fmt.Sprintf("必须小于 %s", fieldError.Param())
+	case "len":
+		return fmt.Sprintf("长度必须为 %s", fieldError.Param())
+	case "oneof":
+		return fmt.Sprintf("必须是 [%s] 之一", fieldError.Param())
+	case "email":
+		return "邮箱格式错误"
+	case "url":
+		return "url格式错误"
+	default:
+		return fmt.Sprintf("校验规则 '%s' 未通过", fieldError.Tag())
+	}
+}
@@ -0,0 +1,87 @@
+package ratgo
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type bindForm struct {
+	Name  string `form:"name" json:"name" binding:"required,max=5"`
+	Age   int    `form:"age" json:"age" binding:"min=18"`
+	Email string `form:"email" json:"email" binding:"omitempty,email"`
+}
+
+// 绑定参数, 成功时响应绑定结果
+type bindController struct {
+	Controller
+}
+
+func (bc *bindController) Exec() {
+	form := &bindForm{}
+	var err error
+	switch bc.Context().Query("by") {
+	case "query":
+		err = bc.BindQuery(form)
+	case "form":
+		err = bc.BindForm(form)
+	default:
+		err = bc.BindJSON(form)
+	}
+	if err != nil {
+		return
+	}
+	bc.Result().SetData(form)
+}
+
+func TestControllerBind_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ws := &WebServer{gin: gin.New()}
+	ws.gin.POST("/bind", ws.restfulHandle(&RestfulRoute{Controller: &bindController{}}))
+	request := func(query string, contentType string, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPost, "/bind?"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", "application/json")
+		recorder := httptest.NewRecorder()
+		ws.gin.ServeHTTP(recorder, req)
+		data := map[string]interface{}{}
+		_ = json.Unmarshal(recorder.Body.Bytes(), &data)
+		return recorder.Code, data
+	}
+	invalid := map[string]interface{}{"Name": "不能为空", "Age": "不能小于 18", "Email": "邮箱格式错误"}
+	cases := []struct {
+		query       string
+		contentType string
+		body        string
+		status      int
+		data        map[string]interface{}
+	}{
+		{"", "application/json", `{"name":"tom","age":20}`, http.StatusOK, map[string]interface{}{"name": "tom", "age": float64(20), "email": ""}},
+		{"", "application/json", `{"age":1,"email":"x"}`, http.StatusBadRequest, invalid},
+		{"by=query&age=1&email=x", "", "", http.StatusBadRequest, invalid},
+		{"by=query&name=tomcat&age=30", "", "", http.StatusBadRequest, map[string]interface{}{"Name": "不能大于 5"}},
+		{"by=form", "application/x-www-form-urlencoded", "age=1&email=x", http.StatusBadRequest, invalid},
+		{"by=form", "application/x-www-form-urlencoded", "name=tom&age=18", http.StatusOK, map[string]interface{}{"name": "tom", "age": float64(18), "email": ""}},
+		{"", "application/json", `{"name":`, http.StatusBadRequest, map[string]interface{}{}},
+		{"by=query&name=tom&age=abc", "", "", http.StatusBadRequest, map[string]interface{}{}},
+	}
+	for _, c := range cases {
+		if status, data := request(c.query, c.contentType, c.body); status != c.status || !reflect.DeepEqual(data, c.data) {
+			t.Errorf("'%s' '%s' receive %d %v", c.query, c.body, status, data)
+		}
+	}
+
+	// 响应信封包含提示信息
+	defer func(responseEnvelope bool) { Config.ResponseEnvelope = responseEnvelope }(Config.ResponseEnvelope)
+	Config.ResponseEnvelope = true
+	if status, data := request("by=query&name=tom", "", ""); status != http.StatusBadRequest || data["msg"] != "参数校验失败" || !reflect.DeepEqual(data["data"], map[string]interface{}{"Age": "不能小于 18"}) {
+		t.Fatal(status, data)
+	}
+	if _, data := request("", "application/json", `{"name":`); !strings.HasPrefix(data["msg"].(string), "参数解析失败") {
+		t.Fatal(data)
+	}
+}
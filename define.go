@@ -9,14 +9,27 @@
 // limitations under the License.
 package ratgo
 
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 /**************************************** 用户挂载函数 ****************************************/
 type UserFunc func() error
 
 // 初始化
-var UserFuncArray []UserFunc
+var (
+	UserFuncArray       []UserFunc // 初始化时执行
+	OnStartFuncArray    []UserFunc // 服务启动监听前执行
+	OnShutdownFuncArray []UserFunc // 服务关闭后执行(系统资源释放之前)
+)
 
 func init() {
 	UserFuncArray = []UserFunc{}
+	OnStartFuncArray = []UserFunc{}
+	OnShutdownFuncArray = []UserFunc{}
 }
 
 // 注册用户挂载函数
@@ -25,18 +38,79 @@ func RegisterUserFunc(userFunc UserFunc) error {
 	return nil
 }
 
+// 注册服务启动函数, 按注册顺序执行, 返回错误时终止启动
+func RegisterOnStart(userFunc UserFunc) error {
+	OnStartFuncArray = append(OnStartFuncArray, userFunc)
+	return nil
+}
+
+// 注册服务关闭函数, 按注册顺序执行, 返回错误仅记录不中断
+func RegisterOnShutdown(userFunc UserFunc) error {
+	OnShutdownFuncArray = append(OnShutdownFuncArray, userFunc)
+	return nil
+}
+
 /**************************************** 数据类型 - 结构体Result ****************************************/
+// 逻辑异常状态码
+const ExceptionStatus = 900
+
+// 状态码映射: Result.Status → http状态码, 未映射的状态码直接作为http状态码
+var statusMapping = map[int]int{
+	ExceptionStatus: 200,
+}
+
+// 注册状态码映射, 亦可通过配置项 StatusMapping = 900:200,901:200 设置
+func RegisterStatusMapping(status int, httpStatus int) {
+	statusMapping[status] = httpStatus
+}
+
+// 获取状态码映射
+func GetStatusMapping(status int) (int, bool) {
+	httpStatus, ok := statusMapping[status]
+	return httpStatus, ok
+}
+
+// 解析状态码映射配置, 格式: 900:200,901:200
+func parseStatusMapping(mapping string) error {
+	for _, item := range strings.Split(mapping, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		pair := strings.Split(item, ":")
+		if len(pair) != 2 {
+			return errors.New(fmt.Sprintf("status mapping '%s' is illegal", item))
+		}
+		status, err := strconv.Atoi(strings.TrimSpace(pair[0]))
+		if err != nil {
+			return errors.New(fmt.Sprintf("status mapping '%s' is illegal", item))
+		}
+		httpStatus, err := strconv.Atoi(strings.TrimSpace(pair[1]))
+		if err != nil {
+			return errors.New(fmt.Sprintf("status mapping '%s' is illegal", item))
+		}
+		RegisterStatusMapping(status, httpStatus)
+	}
+	return nil
+}
+
 // 定义常量
 const (
-	RespString = "String"
-	RespJson   = "Json"
-	RespHtml   = "Html"
+	RespString   = "String"
+	RespJson     = "Json"
+	RespHtml     = "Html"
+	RespXml      = "Xml"
+	RespJsonp    = "Jsonp"
+	RespYaml     = "Yaml"
+	RespProtoBuf = "ProtoBuf" // Data 需实现 proto.Message
+	RespFile     = "File"     // Data 为文件路径, Msg 不为空时作为下载文件名
+	RespStream   = "Stream"   // Data 为 io.Reader(Msg 为 Content-Type) 或 func(w io.Writer) bool
 )
 
 // 响应结果
 type Result struct {
-	Status int         // 状态码: [200:OK] [400:Bad Request] [500:Internal Server Error] [900:逻辑异常(状态码200)]
-	Type   string      // 响应类型: String、Json、Html 默认Json
+	Status int         // 状态码: [200:OK] [400:Bad Request] [500:Internal Server Error] [900:逻辑异常(状态码200)], 参考 RegisterStatusMapping
+	Code   int         // 业务码, 用于响应信封, 状态码被映射且未设置时为原状态码
+	Type   string      // 响应类型: String、Json、Html、Xml、Jsonp、Yaml、ProtoBuf、File、Stream 为空时根据 Accept 协商, 默认 Json
 	Msg    string      // 消息提示
 	Data   interface{} // 响应数据
 }
@@ -45,7 +119,8 @@ type Result struct {
 func NewResult() *Result {
 	return &Result{
 		Status: 200,
-		Type:   RespJson,
+		Code:   0,
+		Type:   "",
 		Msg:    "",
 		Data:   "",
 	}
@@ -57,7 +132,13 @@ func (r *Result) SetStatus(status int) *Result {
 	return r
 }
 
-// 设置Code
+// 设置业务码
+func (r *Result) SetCode(code int) *Result {
+	r.Code = code
+	return r
+}
+
+// 设置Type
 func (r *Result) SetType(t string) *Result {
 	r.Type = t
 	return r
@@ -77,6 +158,6 @@ func (r *Result) SetData(data interface{}) *Result {
 
 // 抛出异常
 func (r *Result) ThrowException() *Result {
-	r.SetStatus(900)
+	r.SetStatus(ExceptionStatus)
 	return r
 }
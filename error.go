@@ -12,11 +12,109 @@ package ratgo
 import (
 	"errors"
 	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
 	"runtime"
 	"strconv"
 	"strings"
 )
 
+/**************************************** 应用错误 ****************************************/
+// 应用错误, 携带http状态码及业务码, 可在控制器中 panic(err) 抛出
+type AppError struct {
+	Status int    // http状态码
+	Code   int    // 业务码
+	Msg    string // 错误信息(响应给客户端)
+	Err    error  // 原始错误(仅记录日志)
+}
+
+// 实例化 AppError
+func NewAppError(status int, code int, msg string) *AppError {
+	return &AppError{
+		Status: status,
+		Code:   code,
+		Msg:    msg,
+	}
+}
+
+// 包装原始错误
+func (e *AppError) Wrap(err error) *AppError {
+	e.Err = err
+	return e
+}
+
+// 错误信息
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Msg, e.Err)
+	}
+	return e.Msg
+}
+
+// 原始错误
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+/**************************************** 错误渲染 ****************************************/
+// 错误渲染函数, stack为错误堆栈信息
+type ErrorRenderer func(context *gin.Context, err error, stack string)
+
+// 默认错误码
+const (
+	DefaultErrorCode = 9999
+	DefaultErrorMsg  = "系统貌似出问题了~"
+)
+
+// 错误渲染
+var errorRenderer ErrorRenderer = DefaultErrorRenderer
+
+// 设置错误渲染函数
+func SetErrorRenderer(renderer ErrorRenderer) {
+	errorRenderer = renderer
+}
+
+// 渲染错误响应
+func RenderError(context *gin.Context, err error, stack string) {
+	errorRenderer(context, err, stack)
+}
+
+// 默认错误渲染: {code,msg,data}, 仅 dev 模式下 data 输出堆栈信息
+func DefaultErrorRenderer(context *gin.Context, err error, stack string) {
+	status, code, msg := http.StatusInternalServerError, DefaultErrorCode, DefaultErrorMsg
+	var appError *AppError
+	if errors.As(err, &appError) {
+		if appError.Status > 0 {
+			status = appError.Status
+		}
+		if appError.Code != 0 {
+			code = appError.Code
+		}
+		if appError.Msg != "" {
+			msg = appError.Msg
+		}
+	}
+	var data interface{} = ""
+	if Config.RunMode == "dev" {
+		data = stack
+	}
+	context.AbortWithStatusJSON(status, map[string]interface{}{
+		"code": code,
+		"msg":  msg,
+		"data": data,
+	})
+}
+
+// 获取请求中捕获的错误(RecoverFunc 中使用)
+func GetError(context *gin.Context) error {
+	if err, ok := context.Get("error"); ok {
+		if e, ok := err.(error); ok {
+			return e
+		}
+	}
+	return nil
+}
+
 // Error info.
 func Error(recover interface{}) error {
 	var array [4096]byte
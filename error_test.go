@@ -0,0 +1,70 @@
+package ratgo
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 按请求参数 panic
+type panicController struct {
+	Controller
+}
+
+func (pc *panicController) Exec() {
+	if pc.Context().Query("type") == "app" {
+		panic(NewAppError(http.StatusConflict, 1001, "订单已存在").Wrap(errors.New("duplicate key 'order_no' dsn=root:pwd@tcp(10.0.0.1)")))
+	}
+	panic("boom")
+}
+
+func TestErrorRenderer_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ws := &WebServer{gin: gin.New()}
+	ws.gin.GET("/panic", ws.restfulHandle(&RestfulRoute{Controller: &panicController{}}))
+	request := func(query string) (int, map[string]interface{}) {
+		recorder := testServe(ws.gin, http.MethodGet, "/panic?"+query, nil)
+		data := map[string]interface{}{}
+		_ = json.Unmarshal(recorder.Body.Bytes(), &data)
+		return recorder.Code, data
+	}
+	defer func(runMode string) { Config.RunMode = runMode }(Config.RunMode)
+
+	// 仅 dev 模式输出堆栈
+	Config.RunMode = "dev"
+	status, data := request("type=app")
+	if status != http.StatusConflict || data["code"] != float64(1001) || data["msg"] != "订单已存在" || !strings.Contains(data["data"].(string), "duplicate key") {
+		t.Fatal(status, data)
+	}
+	for _, runMode := range []string{"prod", "test", ""} {
+		Config.RunMode = runMode
+		status, data = request("type=app")
+		if status != http.StatusConflict || data["msg"] != "订单已存在" || data["data"] != "" {
+			t.Fatal(runMode, status, data)
+		}
+		status, data = request("")
+		if status != http.StatusInternalServerError || data["code"] != float64(DefaultErrorCode) || data["msg"] != DefaultErrorMsg || data["data"] != "" {
+			t.Fatal(runMode, status, data)
+		}
+	}
+
+	// 自定义异常响应通过 GetError 获取错误
+	defer func(recoverFunc func(c *gin.Context)) { Config.RecoverFunc = recoverFunc }(Config.RecoverFunc)
+	Config.RecoverFunc = func(c *gin.Context) {
+		var appError *AppError
+		if err := GetError(c); errors.As(err, &appError) {
+			c.String(http.StatusTeapot, appError.Msg)
+			return
+		}
+		c.String(http.StatusTeapot, GetError(c).Error())
+	}
+	if recorder := testServe(ws.gin, http.MethodGet, "/panic?type=app", nil); recorder.Code != http.StatusTeapot || recorder.Body.String() != "订单已存在" {
+		t.Fatal(recorder.Code, recorder.Body.String())
+	}
+	if recorder := testServe(ws.gin, http.MethodGet, "/panic", nil); recorder.Body.String() != "boom" {
+		t.Fatal(recorder.Body.String())
+	}
+}
@@ -1,6 +1,8 @@
 package ext
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
@@ -11,6 +13,8 @@ import (
 	"gorm.io/gorm/schema"
 	"log"
 	"os"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -35,6 +39,103 @@ type Config struct {
 	SingularTable string        `json:"SingularTable"`
 }
 
+// gorm 连接配置, 对应 database 配置中含 DriverName 的节点
+type GormConfig struct {
+	DriverName                string `validate:"required,oneof=mysql"` // 驱动名称，目前支持: mysql
+	DSN                       string `validate:"required"`             // DSN data source name
+	SkipInitializeWithVersion bool
+	DefaultStringSize         uint
+	DisableDatetimePrecision  bool
+	DontSupportRenameIndex    bool
+	DontSupportRenameColumn   bool
+	DontSupportForShareClause bool
+	NamingStrategy            GormNamingConfig
+	Log                       GormLogConfig
+	ConnPool                  GormPoolConfig
+}
+
+// 命名策略配置
+type GormNamingConfig struct {
+	TablePrefix   string
+	SingularTable bool
+	NameReplacer  []string // 替换前后的字符串, 如 [CID, Cid]
+}
+
+// 字段替换须成对配置
+func (c *GormNamingConfig) Validate() error {
+	if len(c.NameReplacer) != 0 && len(c.NameReplacer) != 2 {
+		return errors.New("the config's 'NameReplacer' must have 2 items")
+	}
+	return nil
+}
+
+// 日志配置
+type GormLogConfig struct {
+	Turn          string // on: 开启
+	Prefix        string
+	SlowThreshold time.Duration `unit:"ms" validate:"min=0s"` // 慢查询阈值, 数值单位毫秒, 也可使用 200ms、1s
+	LogLevel      int           `validate:"min=0,max=4"`      // 1:Silent 2:Error 3:Warn 4:Info
+	Colorful      bool
+}
+
+// 连接池配置
+type GormPoolConfig struct {
+	MaxOpenConn int           `validate:"min=0"`           // 打开数据库连接的最大数量
+	MaxIdleConn int           `validate:"min=0"`           // 空闲连接池中连接的最大数量
+	MaxLifetime time.Duration `unit:"s" validate:"min=0s"` // 连接可复用的最大时间, 数值单位秒, 也可使用 30m
+}
+
+// 读取并校验连接配置
+func loadGormConfig(config types.AnyMap, identification string) (GormConfig, error) {
+	option := GormConfig{}
+	value := config.Get(identification).Value()
+	if value == nil {
+		return option, errors.New(fmt.Sprintf("get config failed by identification '%s'", identification))
+	}
+	if err := types.Unmarshal(value, &option); err != nil {
+		return option, errors.New(fmt.Sprintf("db config '%s' is invalid. %v", identification, err))
+	}
+	return option, nil
+}
+
+// 校验全部含 DriverName 的连接配置, 返回全部错误
+func validateGormConfig(config types.AnyMap) error {
+	errs := types.UnmarshalErrors{}
+	for _, identification := range gormNodes(config, "") {
+		if _, err := loadGormConfig(config, identification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// 全部连接配置标识, 即含 DriverName 的配置节点, 多级使用.拼接(如 plus_center.master)
+func gormNodes(config map[string]interface{}, prefix string) []string {
+	nodes := make([]string, 0)
+	for key, value := range config {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value.(type) {
+		case map[string]string:
+			if _, ok := value.(map[string]string)["DriverName"]; ok {
+				nodes = append(nodes, key)
+			}
+		case map[string]interface{}:
+			if _, ok := value.(map[string]interface{})["DriverName"]; ok {
+				nodes = append(nodes, key)
+			} else {
+				nodes = append(nodes, gormNodes(value.(map[string]interface{}), key)...)
+			}
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
 // *gorm.DB 存储容器
 type DbStorage struct {
 	Abstract
@@ -55,7 +156,10 @@ func init() {
 
 // 根据数据库标识获取对应 *gorm.DB
 func (ds *DbStorage) GormDB(identification string) *gorm.DB {
-	if db, ok := ds.dbMap[identification]; ok {
+	ds.lock.RLock()
+	db, ok := ds.dbMap[identification]
+	ds.lock.RUnlock()
+	if ok {
 		return db
 	}
 	ds.lock.Lock()
@@ -73,62 +177,94 @@ func (ds *DbStorage) GormDB(identification string) *gorm.DB {
 	return db
 }
 
+// 校验全部连接配置, 返回全部错误
+func (ds *DbStorage) Validate() error {
+	return validateGormConfig(ds.config)
+}
+
+// 获取已配置的数据库标识
+func (ds *DbStorage) Identifications() []string {
+	return gormNodes(ds.config, "")
+}
+
+// 检测数据库连接, 未创建时按配置创建
+func (ds *DbStorage) Ping(ctx context.Context, identification string) error {
+	ds.lock.RLock()
+	db, ok := ds.dbMap[identification]
+	ds.lock.RUnlock()
+	if !ok {
+		// 锁外创建连接, 避免建连阻塞其他读取
+		newDB, err := ds.gormDB(identification)
+		if err != nil {
+			return err
+		}
+		ds.lock.Lock()
+		if db, ok = ds.dbMap[identification]; !ok {
+			db = newDB
+			ds.dbMap[identification] = db
+		}
+		ds.lock.Unlock()
+		// 并发创建时关闭本次创建的连接
+		if ok {
+			if sqlDB, err := newDB.DB(); err == nil {
+				_ = sqlDB.Close()
+			}
+		}
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 // 根据 identification 读取配置并获取 *gorm.DB
 func (ds *DbStorage) gormDB(identification string) (db *gorm.DB, err error) {
 	// 读取配置
-	config := types.AnyMap(ds.config.Get(identification).ToAnyMap())
-	if len(config) == 0 {
-		return db, errors.New(fmt.Sprintf("get config failed by identification '%s'", identification))
+	config, err := loadGormConfig(ds.config, identification)
+	if err != nil {
+		return db, err
 	}
 
 	// gorm 配置
 	gormConfig := &gorm.Config{}
 
 	// NamingStrategy来更改命名约定
-	namingStrategyConfig := types.AnyMap(config.Get("NamingStrategy").ToAnyMap())
-	if len(namingStrategyConfig) > 0 {
-		namingStrategy := schema.NamingStrategy{}
-		if tablePrefix := namingStrategyConfig.Get("TablePrefix").ToString(); tablePrefix != "" {
-			namingStrategy.TablePrefix = tablePrefix
-		}
-		if _, ok := namingStrategyConfig["SingularTable"]; ok {
-			namingStrategy.SingularTable = namingStrategyConfig.Get("SingularTable").ToBool()
+	if naming := config.NamingStrategy; naming.TablePrefix != "" || naming.SingularTable || len(naming.NameReplacer) == 2 {
+		namingStrategy := schema.NamingStrategy{
+			TablePrefix:   naming.TablePrefix,
+			SingularTable: naming.SingularTable,
 		}
-		if _, ok := namingStrategyConfig["NameReplacer"]; ok {
-			nameReplacer := namingStrategyConfig.Get("NameReplacer").ToStringSlice()
-			if len(nameReplacer) == 2 {
-				namingStrategy.NameReplacer = strings.NewReplacer(nameReplacer[0], nameReplacer[1])
-			}
+		if len(naming.NameReplacer) == 2 {
+			namingStrategy.NameReplacer = strings.NewReplacer(naming.NameReplacer[0], naming.NameReplacer[1])
 		}
 		gormConfig.NamingStrategy = namingStrategy
 	}
 
 	// 日志输出
-	logConfig := types.AnyMap(config.Get("Log").ToAnyMap())
-	if logConfig.Get("Turn").ToString() == "on" {
-		gormConfig.Logger = logger.New(log.New(os.Stdout, "\r\n"+logConfig.Get("Prefix").ToString(), log.LstdFlags), logger.Config{
-			SlowThreshold: time.Duration(logConfig.Get("SlowThreshold").ToInt64()) * time.Millisecond,
-			LogLevel:      logger.LogLevel(logConfig.Get("LogLevel").ToInt()),
-			Colorful:      logConfig.Get("Colorful").ToBool(),
+	if config.Log.Turn == "on" {
+		gormConfig.Logger = logger.New(log.New(os.Stdout, "\r\n"+config.Log.Prefix, log.LstdFlags), logger.Config{
+			SlowThreshold: config.Log.SlowThreshold,
+			LogLevel:      logger.LogLevel(config.Log.LogLevel),
+			Colorful:      config.Log.Colorful,
 		})
 	}
 
 	// 选择数据库驱动
-	driverName := config.Get("DriverName").ToString()
-	switch driverName {
+	switch config.DriverName {
 	case "mysql":
 		db, err = gorm.Open(mysql.New(mysql.Config{
-			DSN:                       config.Get("DSN").ToString(),
-			SkipInitializeWithVersion: config.Get("SkipInitializeWithVersion").ToBool(),
-			DefaultStringSize:         uint(config.Get("DefaultStringSize").ToInt()),
+			DSN:                       config.DSN,
+			SkipInitializeWithVersion: config.SkipInitializeWithVersion,
+			DefaultStringSize:         config.DefaultStringSize,
 			//DefaultDatetimePrecision:  &config.Get("DefaultDatetimePrecision").ToInt(),
-			DisableDatetimePrecision:  config.Get("DisableDatetimePrecision").ToBool(),
-			DontSupportRenameIndex:    config.Get("DontSupportRenameIndex").ToBool(),
-			DontSupportRenameColumn:   config.Get("DontSupportRenameColumn").ToBool(),
-			DontSupportForShareClause: config.Get("DontSupportForShareClause").ToBool(),
+			DisableDatetimePrecision:  config.DisableDatetimePrecision,
+			DontSupportRenameIndex:    config.DontSupportRenameIndex,
+			DontSupportRenameColumn:   config.DontSupportRenameColumn,
+			DontSupportForShareClause: config.DontSupportForShareClause,
 		}), gormConfig)
 	default:
-		err = errors.New(fmt.Sprintf("get db driver '%s' failed.", driverName))
+		err = errors.New(fmt.Sprintf("get db driver '%s' failed.", config.DriverName))
 	}
 	if err != nil {
 		return
@@ -146,15 +282,72 @@ func (ds *DbStorage) gormDB(identification string) (db *gorm.DB, err error) {
 	if sqlDB, err := db.DB(); err != nil {
 		return db, err
 	} else {
-		connPoolConfig := types.AnyMap(config.Get("ConnPool").ToAnyMap())
-		sqlDB.SetMaxOpenConns(connPoolConfig.Get("MaxOpenConn").ToInt())                                   // SetMaxOpenConns 设置打开数据库连接的最大数量。
-		sqlDB.SetMaxIdleConns(connPoolConfig.Get("MaxIdleConn").ToInt())                                   // SetMaxIdleConns 用于设置连接池中空闲连接的最大数
-		sqlDB.SetConnMaxLifetime(time.Duration(connPoolConfig.Get("maxLifetime").ToInt64()) * time.Second) // SetConnMaxLifetime 设置了连接可复用的最大时间
+		sqlDB.SetMaxOpenConns(config.ConnPool.MaxOpenConn)    // SetMaxOpenConns 设置打开数据库连接的最大数量。
+		sqlDB.SetMaxIdleConns(config.ConnPool.MaxIdleConn)    // SetMaxIdleConns 用于设置连接池中空闲连接的最大数
+		sqlDB.SetConnMaxLifetime(config.ConnPool.MaxLifetime) // SetConnMaxLifetime 设置了连接可复用的最大时间
 	}
 	return db, nil
 }
 
+// 获取全部已创建的 *sql.DB
+func (ds *DbStorage) SqlDBs() map[string]*sql.DB {
+	ds.lock.RLock()
+	defer ds.lock.RUnlock()
+	dbMap := map[string]*sql.DB{}
+	for identification, db := range ds.dbMap {
+		if sqlDB, err := db.DB(); err == nil {
+			dbMap[identification] = sqlDB
+		}
+	}
+	return dbMap
+}
+
+// 重新加载配置, 关闭配置已变更的连接, 下次获取时按新配置创建, 配置校验失败时保留原配置
+func (ds *DbStorage) Reload(config map[string]interface{}) error {
+	if err := validateGormConfig(config); err != nil {
+		return err
+	}
+	ds.lock.Lock()
+	oldConfig := ds.config
+	ds.config = config
+	closeDbMap := map[string]*gorm.DB{}
+	for identification, db := range ds.dbMap {
+		if !reflect.DeepEqual(oldConfig.Get(identification).Value(), ds.config.Get(identification).Value()) {
+			closeDbMap[identification] = db
+			delete(ds.dbMap, identification)
+		}
+	}
+	ds.lock.Unlock()
+
+	// 关闭旧连接, 等待使用中的连接释放
+	var closeErr error
+	for identification, db := range closeDbMap {
+		if sqlDB, err := db.DB(); err != nil {
+			closeErr = err
+		} else if err = sqlDB.Close(); err != nil {
+			closeErr = errors.New(fmt.Sprintf("close db '%s' failed. error:%v", identification, err))
+		}
+	}
+	return closeErr
+}
+
 // 注册插件
 func (ds *DbStorage) RegisterPlugins(identification string, plugin gorm.Plugin) {
 	ds.Plugins[identification] = plugin
 }
+
+// 关闭全部连接池
+func (ds *DbStorage) Close() error {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	var closeErr error
+	for identification, db := range ds.dbMap {
+		if sqlDB, err := db.DB(); err != nil {
+			closeErr = err
+		} else if err = sqlDB.Close(); err != nil {
+			closeErr = errors.New(fmt.Sprintf("close db '%s' failed. error:%v", identification, err))
+		}
+	}
+	ds.dbMap = map[string]*gorm.DB{}
+	return closeErr
+}
@@ -6,8 +6,9 @@ import (
 	"github.com/FZambia/sentinel"
 	"github.com/garyburd/redigo/redis"
 	sentinelRedis "github.com/gomodule/redigo/redis"
-	"strconv"
-	"strings"
+	"github.com/vdongchina/ratgo/utils/types"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
 )
@@ -29,10 +30,87 @@ func init() {
 	}
 }
 
+// redis 连接配置, 配置 MasterName 时使用 Sentinel 连接池
+type RedisConfig struct {
+	Host        string   // 地址, 如 127.0.0.1:6379
+	HostArray   []string // Sentinel 地址, 多个使用,拼接
+	MasterName  string   // Sentinel 主节点名称
+	Password    string
+	Db          int           `validate:"min=0"`
+	MaxIdle     int           `validate:"min=0"`
+	MaxActive   int           `validate:"min=0"`
+	IdleTimeout time.Duration `unit:"ms" validate:"min=0s"` // 连接及空闲超时时间, 数值单位毫秒, 也可使用 500ms、3s
+	// Sentinel 连接池空闲超时时间, 读取 IdleTimeout, 数值单位沿用秒
+	PoolIdleTimeout time.Duration `config:"IdleTimeout" unit:"s" validate:"min=0s"`
+}
+
+// 地址校验
+func (c *RedisConfig) Validate() error {
+	if c.MasterName == "" && c.Host == "" {
+		return errors.New("the config's 'Host' can't be empty")
+	}
+	if c.MasterName != "" && len(c.HostArray) == 0 {
+		return errors.New("the config's 'HostArray' can't be empty")
+	}
+	return nil
+}
+
+// 读取并校验连接配置
+func loadRedisConfig(config types.AnyMap, identify string) (RedisConfig, error) {
+	option := RedisConfig{}
+	value := config.Get(identify).Value()
+	if value == nil {
+		return option, errors.New(fmt.Sprintf("get config by identify '%s' failed.", identify))
+	}
+	if err := types.Unmarshal(value, &option); err != nil {
+		return option, errors.New(fmt.Sprintf("redis config '%s' is invalid. %v", identify, err))
+	}
+	return option, nil
+}
+
+// 校验全部连接配置, 返回全部错误
+func validateRedisConfig(config types.AnyMap) error {
+	errs := types.UnmarshalErrors{}
+	for _, identify := range redisNodes(config, "") {
+		if _, err := loadRedisConfig(config, identify); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// 全部连接配置标识, 即不含子节点的配置节点, 多级使用.拼接(如 plus_center.master)
+func redisNodes(config map[string]interface{}, prefix string) []string {
+	nodes := make([]string, 0)
+	for key, value := range config {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value.(type) {
+		case map[string]string:
+			nodes = append(nodes, key)
+		case map[string]interface{}:
+			if children := redisNodes(value.(map[string]interface{}), key); len(children) > 0 {
+				nodes = append(nodes, children...)
+			} else {
+				nodes = append(nodes, key)
+			}
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
 // 获取redis Pool
 func (rc *RedisCache) Pool(identify string) *redis.Pool {
 	// 读取缓存
-	if redisPool, ok := rc.pool[identify]; ok {
+	rc.RLock()
+	redisPool, ok := rc.pool[identify]
+	rc.RUnlock()
+	if ok {
 		return redisPool
 	}
 	// 加锁
@@ -42,52 +120,27 @@ func (rc *RedisCache) Pool(identify string) *redis.Pool {
 		return redisPool
 	}
 	// 读取配置
-	config := rc.config.Get(identify).ToStringMap()
-	if len(config) == 0 {
-		panic(fmt.Sprintf("get config by identify '%s' failed.", identify))
-	}
-	// 参数校验
-	stringMap := map[string]string{
-		"Host":     "",
-		"Password": "",
-	}
-	for key := range stringMap {
-		if value, ok := config[key]; ok {
-			stringMap[key] = value
-		}
-	}
-	for key, value := range stringMap {
-		if value == "" && key != "Password" {
-			panic(fmt.Sprintf("the config's '%s' can't be empty.", key))
-		}
-	}
-
-	// 整型参数校验
-	intMap := map[string]int{"Db": 0, "MaxIdle": 0, "MaxActive": 0, "IdleTimeout": 0}
-	for key := range intMap {
-		if value, ok := config[key]; ok {
-			if v, err := strconv.Atoi(value); err == nil {
-				intMap[key] = v
-			}
-		}
+	config, err := loadRedisConfig(rc.config, identify)
+	if err != nil {
+		panic(err.Error())
 	}
 
 	// 创建连接池
 	rc.pool[identify] = &redis.Pool{
-		MaxIdle:     intMap["MaxIdle"],
-		MaxActive:   intMap["MaxActive"],
-		IdleTimeout: time.Duration(intMap["IdleTimeout"]),
+		MaxIdle:     config.MaxIdle,
+		MaxActive:   config.MaxActive,
+		IdleTimeout: config.IdleTimeout,
 		Dial: func() (redis.Conn, error) {
 			dialOption := []redis.DialOption{
-				redis.DialReadTimeout(time.Duration(intMap["IdleTimeout"]) * time.Millisecond),
-				redis.DialWriteTimeout(time.Duration(intMap["IdleTimeout"]) * time.Millisecond),
-				redis.DialConnectTimeout(time.Duration(intMap["IdleTimeout"]) * time.Millisecond),
-				redis.DialDatabase(intMap["Db"]),
+				redis.DialReadTimeout(config.IdleTimeout),
+				redis.DialWriteTimeout(config.IdleTimeout),
+				redis.DialConnectTimeout(config.IdleTimeout),
+				redis.DialDatabase(config.Db),
 			}
-			if stringMap["Password"] != "" {
-				dialOption = append(dialOption, redis.DialPassword(stringMap["Password"]))
+			if config.Password != "" {
+				dialOption = append(dialOption, redis.DialPassword(config.Password))
 			}
-			return redis.Dial("tcp", stringMap["Host"], dialOption...)
+			return redis.Dial("tcp", config.Host, dialOption...)
 		},
 	}
 	return rc.pool[identify]
@@ -96,7 +149,10 @@ func (rc *RedisCache) Pool(identify string) *redis.Pool {
 // 获取redis SentinelPool
 func (rc *RedisCache) SentinelPool(identify string) *sentinelRedis.Pool {
 	// 读取缓存
-	if redisPool, ok := rc.sentinelPool[identify]; ok {
+	rc.RLock()
+	redisPool, ok := rc.sentinelPool[identify]
+	rc.RUnlock()
+	if ok {
 		return redisPool
 	}
 	// 加锁
@@ -106,30 +162,15 @@ func (rc *RedisCache) SentinelPool(identify string) *sentinelRedis.Pool {
 		return redisPool
 	}
 	// 读取配置
-	config := rc.config.Get(identify).ToStringMap()
-	if len(config) == 0 {
-		panic(fmt.Sprintf("get config by identify '%s' failed.", identify))
+	config, err := loadRedisConfig(rc.config, identify)
+	if err != nil {
+		panic(err.Error())
 	}
 
 	// Sentinel类
-	stringMap := map[string]string{
-		"HostArray":  "",
-		"MasterName": "",
-		"Password":   "",
-	}
-	for key := range stringMap {
-		if value, ok := config[key]; ok {
-			stringMap[key] = value
-		}
-	}
-	for key, value := range stringMap {
-		if value == "" && key != "Password" {
-			panic(fmt.Sprintf("the config's '%s' can't be empty.", key))
-		}
-	}
 	st := &sentinel.Sentinel{
-		Addrs:      strings.Split(stringMap["HostArray"], ","),
-		MasterName: stringMap["MasterName"],
+		Addrs:      config.HostArray,
+		MasterName: config.MasterName,
 		Dial: func(addr string) (sentinelRedis.Conn, error) {
 			timeout := 500 * time.Millisecond
 			c, err := sentinelRedis.DialTimeout("tcp", addr, timeout, timeout, timeout)
@@ -141,19 +182,11 @@ func (rc *RedisCache) SentinelPool(identify string) *sentinelRedis.Pool {
 	}
 
 	// Sentinel 连接池
-	intMap := map[string]int{"Db": 0, "MaxIdle": 0, "MaxActive": 0, "IdleTimeout": 0}
-	for key := range intMap {
-		if value, ok := config[key]; ok {
-			if v, err := strconv.Atoi(value); err == nil {
-				intMap[key] = v
-			}
-		}
-	}
 	rc.sentinelPool[identify] = &sentinelRedis.Pool{
-		MaxIdle:     intMap["MaxIdle"],
-		MaxActive:   intMap["MaxActive"],
+		MaxIdle:     config.MaxIdle,
+		MaxActive:   config.MaxActive,
 		Wait:        true,
-		IdleTimeout: time.Duration(intMap["IdleTimeout"]) * time.Second,
+		IdleTimeout: config.PoolIdleTimeout,
 		Dial: func() (sentinelRedis.Conn, error) {
 			// 主地址
 			masterAddr, err := st.MasterAddr()
@@ -162,13 +195,13 @@ func (rc *RedisCache) SentinelPool(identify string) *sentinelRedis.Pool {
 			}
 			// dial设置
 			dialOption := []sentinelRedis.DialOption{
-				sentinelRedis.DialReadTimeout(time.Duration(intMap["IdleTimeout"]) * time.Millisecond),
-				sentinelRedis.DialWriteTimeout(time.Duration(intMap["IdleTimeout"]) * time.Millisecond),
-				sentinelRedis.DialConnectTimeout(time.Duration(intMap["IdleTimeout"]) * time.Millisecond),
-				sentinelRedis.DialDatabase(intMap["Db"]),
+				sentinelRedis.DialReadTimeout(config.IdleTimeout),
+				sentinelRedis.DialWriteTimeout(config.IdleTimeout),
+				sentinelRedis.DialConnectTimeout(config.IdleTimeout),
+				sentinelRedis.DialDatabase(config.Db),
 			}
-			if stringMap["Password"] != "" {
-				dialOption = append(dialOption, sentinelRedis.DialPassword(stringMap["Password"]))
+			if config.Password != "" {
+				dialOption = append(dialOption, sentinelRedis.DialPassword(config.Password))
 			}
 			c, err := sentinelRedis.Dial("tcp", masterAddr, dialOption...)
 			if err != nil {
@@ -186,3 +219,113 @@ func (rc *RedisCache) SentinelPool(identify string) *sentinelRedis.Pool {
 	}
 	return rc.sentinelPool[identify]
 }
+
+// 校验全部连接配置, 返回全部错误
+func (rc *RedisCache) Validate() error {
+	return validateRedisConfig(rc.config)
+}
+
+// 获取已配置的连接标识
+func (rc *RedisCache) Identifications() []string {
+	return redisNodes(rc.config, "")
+}
+
+// 检测连接, 配置含 MasterName 时使用 Sentinel 连接池
+func (rc *RedisCache) Ping(identify string) error {
+	config := rc.config.Get(identify).ToStringMap()
+	if len(config) == 0 {
+		return errors.New(fmt.Sprintf("get config by identify '%s' failed.", identify))
+	}
+	var err error
+	if config["MasterName"] != "" {
+		conn := rc.SentinelPool(identify).Get()
+		defer conn.Close()
+		_, err = conn.Do("PING")
+	} else {
+		conn := rc.Pool(identify).Get()
+		defer conn.Close()
+		_, err = conn.Do("PING")
+	}
+	return err
+}
+
+// 获取全部已创建的连接池
+func (rc *RedisCache) Pools() map[string]*redis.Pool {
+	rc.RLock()
+	defer rc.RUnlock()
+	pools := make(map[string]*redis.Pool, len(rc.pool))
+	for identify, pool := range rc.pool {
+		pools[identify] = pool
+	}
+	return pools
+}
+
+// 获取全部已创建的 Sentinel 连接池
+func (rc *RedisCache) SentinelPools() map[string]*sentinelRedis.Pool {
+	rc.RLock()
+	defer rc.RUnlock()
+	pools := make(map[string]*sentinelRedis.Pool, len(rc.sentinelPool))
+	for identify, pool := range rc.sentinelPool {
+		pools[identify] = pool
+	}
+	return pools
+}
+
+// 重新加载配置, 关闭配置已变更的连接池, 下次获取时按新配置创建, 配置校验失败时保留原配置
+func (rc *RedisCache) Reload(config map[string]interface{}) error {
+	if err := validateRedisConfig(config); err != nil {
+		return err
+	}
+	rc.Lock()
+	oldConfig := rc.config
+	rc.config = config
+	closePools := map[string]*redis.Pool{}
+	for identify, pool := range rc.pool {
+		if !reflect.DeepEqual(oldConfig.Get(identify).Value(), rc.config.Get(identify).Value()) {
+			closePools[identify] = pool
+			delete(rc.pool, identify)
+		}
+	}
+	closeSentinelPools := map[string]*sentinelRedis.Pool{}
+	for identify, pool := range rc.sentinelPool {
+		if !reflect.DeepEqual(oldConfig.Get(identify).Value(), rc.config.Get(identify).Value()) {
+			closeSentinelPools[identify] = pool
+			delete(rc.sentinelPool, identify)
+		}
+	}
+	rc.Unlock()
+
+	// 关闭旧连接池, 使用中的连接归还时关闭
+	var closeErr error
+	for identify, pool := range closePools {
+		if err := pool.Close(); err != nil {
+			closeErr = errors.New(fmt.Sprintf("close redis pool '%s' failed. error:%v", identify, err))
+		}
+	}
+	for identify, pool := range closeSentinelPools {
+		if err := pool.Close(); err != nil {
+			closeErr = errors.New(fmt.Sprintf("close redis sentinel pool '%s' failed. error:%v", identify, err))
+		}
+	}
+	return closeErr
+}
+
+// 关闭全部连接池
+func (rc *RedisCache) Close() error {
+	rc.Lock()
+	defer rc.Unlock()
+	var closeErr error
+	for identify, pool := range rc.pool {
+		if err := pool.Close(); err != nil {
+			closeErr = errors.New(fmt.Sprintf("close redis pool '%s' failed. error:%v", identify, err))
+		}
+	}
+	for identify, pool := range rc.sentinelPool {
+		if err := pool.Close(); err != nil {
+			closeErr = errors.New(fmt.Sprintf("close redis sentinel pool '%s' failed. error:%v", identify, err))
+		}
+	}
+	rc.pool = map[string]*redis.Pool{}
+	rc.sentinelPool = map[string]*sentinelRedis.Pool{}
+	return closeErr
+}
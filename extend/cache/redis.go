@@ -75,3 +75,15 @@ func (rc *RedisCache) Pool(identify string) *redis.Pool {
 	}
 	return rc.container[identify]
 }
+
+// Close all redis pool.
+func (rc *RedisCache) Close() error {
+	var closeErr error
+	for identify, pool := range rc.container {
+		if err := pool.Close(); err != nil {
+			closeErr = errors.New("close redis pool '" + identify + "' failed. error:" + err.Error())
+		}
+	}
+	rc.container = make(map[string]*redis.Pool)
+	return closeErr
+}
@@ -1,9 +1,15 @@
 package cache
 
 import (
+	"context"
 	"errors"
 	"github.com/garyburd/redigo/redis"
+	"github.com/vdongchina/ratgo/utils/trace"
 	"github.com/vdongchina/ratgo/utils/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"strings"
 )
 
 type Identify interface {
@@ -12,6 +18,7 @@ type Identify interface {
 
 type RedisDao struct {
 	pool *redis.Pool
+	ctx  context.Context
 }
 
 // 获取RedisDao对象
@@ -30,8 +37,31 @@ func (rd *RedisDao) Pool() *redis.Pool {
 	return rd.pool
 }
 
+// 设置链路追踪 context, 返回新的 RedisDao, 设置后记录命令执行 span
+func (rd *RedisDao) WithContext(ctx context.Context) *RedisDao {
+	return &RedisDao{pool: rd.pool, ctx: ctx}
+}
+
 // 执行redis命令
 func (rd *RedisDao) Command(name string, args ...interface{}) *types.AnyValue {
+	if rd.ctx != nil {
+		_, span := trace.Start(rd.ctx, "redis."+strings.ToUpper(name),
+			oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+			oteltrace.WithAttributes(attribute.String("db.system", "redis"), attribute.String("db.operation", strings.ToUpper(name))),
+		)
+		defer span.End()
+		result := rd.command(name, args...)
+		if err := result.ToError(); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return result
+	}
+	return rd.command(name, args...)
+}
+
+// 执行redis命令
+func (rd *RedisDao) command(name string, args ...interface{}) *types.AnyValue {
 	redisConn := rd.Pool().Get()
 	defer func() { _ = redisConn.Close() }()
 	result, err := redisConn.Do(name, args...)
@@ -2,6 +2,7 @@ package db
 
 import (
 	"errors"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -81,3 +82,31 @@ func (cs *ConfigStorage) GetConfig(arg string) (config map[string]string, err er
 	}
 	return config, nil
 }
+
+// 获取已配置的数据库标识, 即含 driverName 的配置节点, 多级使用.拼接
+func (cs *ConfigStorage) Identifications() []string {
+	identifications := make([]string, 0)
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		switch value.(type) {
+		case map[string]string:
+			if _, ok := value.(map[string]string)["driverName"]; ok && prefix != "" {
+				identifications = append(identifications, prefix)
+			}
+		case map[string]interface{}:
+			if _, ok := value.(map[string]interface{})["driverName"]; ok && prefix != "" {
+				identifications = append(identifications, prefix)
+				return
+			}
+			for key, v := range value.(map[string]interface{}) {
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				walk(key, v)
+			}
+		}
+	}
+	walk("", cs.config)
+	sort.Strings(identifications)
+	return identifications
+}
@@ -1,12 +1,19 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/vdongchina/ratgo/extend/db/model"
 	"github.com/vdongchina/ratgo/extend/db/query"
+	"github.com/vdongchina/ratgo/utils/metrics"
+	"github.com/vdongchina/ratgo/utils/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // 用户自定义函数
@@ -18,6 +25,7 @@ type Dao struct {
 	query query.BaseQuery
 	isSQL bool
 	isTx  bool
+	ctx   context.Context
 }
 
 // 注册model
@@ -51,6 +59,12 @@ func Model(userModel interface{}) *Dao {
 	}
 }
 
+// 设置链路追踪 context, 设置后记录 SQL 执行 span
+func (d *Dao) WithContext(ctx context.Context) *Dao {
+	d.ctx = ctx
+	return d
+}
+
 // 执行SQL - 查询一条数据
 func (d *Dao) QueryRow(sql string, args ...interface{}) *AnyValue {
 	defer d.reset()
@@ -68,6 +82,7 @@ func (d *Dao) QueryRow(sql string, args ...interface{}) *AnyValue {
 // 执行SQL - 增删改查
 func (d *Dao) Query(sql string, args ...interface{}) *AnyValue {
 	defer d.reset()
+	defer d.observe("Query")()
 	sqlArray := strings.Split(sql, " ")
 	switch strings.ToUpper(sqlArray[0]) {
 	case "SELECT":
@@ -205,6 +220,7 @@ func (d *Dao) Sql() *Dao {
 // 查询一条记录
 func (d *Dao) FetchRow(userFunc ...UserFunc) *AnyValue {
 	defer d.reset()
+	defer d.observe("FetchRow")()
 	_ = d.query.Limit(1)
 	_ = d.fetch(userFunc...)
 	if d.isSQL {
@@ -218,6 +234,7 @@ func (d *Dao) FetchRow(userFunc ...UserFunc) *AnyValue {
 // 查询多条记录
 func (d *Dao) FetchAll(userFunc ...UserFunc) *AnyValue {
 	defer d.reset()
+	defer d.observe("FetchAll")()
 	_ = d.fetch(userFunc...)
 	if d.isSQL {
 		return Eval(d.query.GetSql())
@@ -289,6 +306,7 @@ func (d *Dao) fetch(userFunc ...UserFunc) *AnyValue {
 
 // 执行过程
 func (d *Dao) modify(sType string, userFunc ...UserFunc) *AnyValue {
+	defer d.observe(sType)()
 	_ = d.query.SetSqlType(sType)
 	// 执行过程
 	if userFunc != nil {
@@ -373,6 +391,37 @@ func (d *Dao) reset() {
 	d.log()
 }
 
+// 记录 SQL 执行耗时指标及 span(已设置 context 时), 仅打印 SQL 时跳过
+func (d *Dao) observe(operation string) func() {
+	startTime := time.Now()
+	return func() {
+		if d.isSQL {
+			return
+		}
+		runTime := d.query.GetDuration()
+		if execTime, err := time.ParseDuration(runTime.ExecTime); err == nil {
+			metrics.ObserveDB(d.table.Identify, operation, execTime, runTime.Err)
+		}
+		if d.ctx == nil {
+			return
+		}
+		_, span := trace.Start(d.ctx, "db."+operation,
+			oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+			oteltrace.WithTimestamp(startTime),
+			oteltrace.WithAttributes(
+				attribute.String("db.system", "mysql"),
+				attribute.String("db.name", d.table.Identify),
+				attribute.String("db.statement", runTime.Sql),
+			),
+		)
+		if runTime.Err != nil {
+			span.RecordError(runTime.Err)
+			span.SetStatus(codes.Error, runTime.Err.Error())
+		}
+		span.End()
+	}
+}
+
 // Runtime data.
 func (d *Dao) log() {
 	if Config.LogWriter != nil {
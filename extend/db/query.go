@@ -1,11 +1,14 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/vdongchina/ratgo/extend/db/query"
 	"strconv"
+	"sync"
 )
 
 // 查询构造器容器
@@ -13,24 +16,55 @@ type QueryContainer map[string]query.BaseQuery
 
 var Query *QueryContainer
 
+// Query 读写锁, 请求、健康检查及指标采集并发访问
+var queryLock sync.RWMutex
+
 func init() {
 	Query = &QueryContainer{}
 }
 
 // 设置queryBuilder
 func (qc *QueryContainer) Set(key string, queryBuilder query.BaseQuery) error {
+	queryLock.Lock()
+	defer queryLock.Unlock()
 	(*qc)[key] = queryBuilder
 	return nil
 }
 
+// 未设置时存储 queryBuilder, 返回最终存储的 queryBuilder 及是否为本次存储
+func (qc *QueryContainer) setIfAbsent(key string, queryBuilder query.BaseQuery) (query.BaseQuery, bool) {
+	queryLock.Lock()
+	defer queryLock.Unlock()
+	if existing, ok := (*qc)[key]; ok {
+		return existing, false
+	}
+	(*qc)[key] = queryBuilder
+	return queryBuilder, true
+}
+
 // 获取queryBuilder
 func (qc *QueryContainer) Get(key string) (query.BaseQuery, error) {
+	queryLock.RLock()
+	defer queryLock.RUnlock()
 	if queryBuilder, ok := (*qc)[key]; ok {
 		return queryBuilder, nil
 	}
 	return nil, errors.New("can't find this Query Builder '" + key + "'")
 }
 
+// 获取全部已创建的 *sql.DB
+func (qc *QueryContainer) SqlDBs() map[string]*sql.DB {
+	queryLock.RLock()
+	defer queryLock.RUnlock()
+	dbMap := map[string]*sql.DB{}
+	for key, queryBuilder := range *qc {
+		if db := queryBuilder.GetDb(); db != nil {
+			dbMap[key] = db
+		}
+	}
+	return dbMap
+}
+
 // 获取查询构造器
 func GetQueryBuilder(identify string) query.BaseQuery {
 	if queryBuilder, err := Query.Get(identify); err == nil {
@@ -66,7 +100,21 @@ func GetQueryBuilder(identify string) query.BaseQuery {
 			db.SetMaxOpenConns(maxOpenConn)
 		}
 		queryBuilder.SetDb(db)
-		_ = Query.Set(identify, queryBuilder)
-		return queryBuilder.Clone()
+		// 并发创建时使用先存储的连接, 关闭本次创建的连接
+		stored, ok := Query.setIfAbsent(identify, queryBuilder)
+		if !ok {
+			_ = db.Close()
+		}
+		return stored.Clone()
 	}
 }
+
+// 检测数据库连接, 未创建时按配置创建
+func Ping(ctx context.Context, identify string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprint(r))
+		}
+	}()
+	return GetQueryBuilder(identify).GetDb().PingContext(ctx)
+}
@@ -26,6 +26,11 @@ func (c *Combine) SetDb(db *sql.DB) {
 	c.db = db
 }
 
+// Get Db.
+func (c *Combine) GetDb() *sql.DB {
+	return c.db
+}
+
 // Set Tx.
 func (c *Combine) SetTx(tx *sql.Tx) {
 	c.tx = tx
@@ -9,6 +9,7 @@ type BaseQuery interface {
 	Clone() BaseQuery
 	Reset() error
 	SetDb(db *sql.DB)
+	GetDb() *sql.DB
 	SetTx(tx *sql.Tx)
 	UnsetTx()
 	GetTx() *sql.Tx
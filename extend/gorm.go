@@ -10,6 +10,7 @@
 package extend
 
 import (
+	"errors"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
@@ -129,3 +130,17 @@ func (ds *DbStorage) gormDb(config DbConfig) *gorm.DB {
 	}
 	return gormDB
 }
+
+// 关闭全部连接
+func (ds *DbStorage) Close() error {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	var closeErr error
+	for key, db := range ds.dbMap {
+		if err := db.Close(); err != nil {
+			closeErr = errors.New(fmt.Sprintf("close db '%s' failed. error:%v", key, err))
+		}
+	}
+	ds.dbMap = map[string]*gorm.DB{}
+	return closeErr
+}
@@ -0,0 +1,266 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/ext"
+	"github.com/vdongchina/ratgo/extend/db"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 健康检查配置, 对应 ratgo 配置 health 节点
+type HealthOption struct {
+	Enable        bool
+	LivePath      string // 存活检查路径, 不检测依赖, 默认 /livez
+	HealthPath    string // 健康检查路径, 默认 /healthz
+	ReadyPath     string // 就绪检查路径, 服务关闭期间返回 503, 默认 /readyz
+	Timeout       int    // 单项检查超时时间(毫秒), 默认 3000
+	ShutdownDelay int    // 优雅关闭前等待时间(秒), 期间就绪检查失败, 便于负载均衡摘除流量
+	Token         string // 查看检查详情的令牌(请求头 Authorization: Bearer xxx), 未携带时仅响应 up/down
+	CacheTTL      int    // 检查结果缓存时间(毫秒), 默认 1000, 期间的请求不再访问依赖
+}
+
+// 健康检查函数
+type HealthChecker func(ctx context.Context) error
+
+// 检查状态
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+)
+
+// 单项检查结果
+type HealthResult struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency"` // 耗时(毫秒)
+	Error   string  `json:"error,omitempty"`
+}
+
+// 检查报告
+type HealthReport struct {
+	Status       string         `json:"status"`
+	ShuttingDown bool           `json:"shuttingDown,omitempty"`
+	Latency      float64        `json:"latency,omitempty"` // 总耗时(毫秒)
+	Checks       []HealthResult `json:"checks,omitempty"`
+}
+
+// 健康检查容器
+type HealthStorage struct {
+	lock         sync.RWMutex
+	checkers     map[string]HealthChecker
+	shuttingDown int32
+	checkLock    sync.Mutex // 同一时间仅执行一次检查
+	cached       HealthReport
+	cachedAt     time.Time
+}
+
+var Health *HealthStorage
+
+func init() {
+	Health = &HealthStorage{
+		checkers: map[string]HealthChecker{},
+	}
+}
+
+// 默认健康检查配置
+func DefaultHealthOption() HealthOption {
+	return HealthOption{
+		LivePath:   "/livez",
+		HealthPath: "/healthz",
+		ReadyPath:  "/readyz",
+		Timeout:    3000,
+		CacheTTL:   1000,
+	}
+}
+
+// 读取健康检查配置
+func healthOption() HealthOption {
+	option := DefaultHealthOption()
+	loadOption(&option, Config.GetApp("health").ToAnyMap())
+	return option
+}
+
+// 注册自定义检查, 相同名称覆盖
+func (hs *HealthStorage) Register(name string, checker HealthChecker) {
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+	hs.checkers[name] = checker
+}
+
+// 注册自定义检查
+func RegisterHealthChecker(name string, checker HealthChecker) {
+	Health.Register(name, checker)
+}
+
+// 标记服务关闭中, 就绪检查开始失败
+func (hs *HealthStorage) SetShuttingDown() {
+	atomic.StoreInt32(&hs.shuttingDown, 1)
+}
+
+// 服务是否关闭中
+func (hs *HealthStorage) ShuttingDown() bool {
+	return atomic.LoadInt32(&hs.shuttingDown) == 1
+}
+
+// 全部检查项: 已配置的 ext.GormV2、extend/db 数据库, ext.Redis 连接池及自定义检查
+func (hs *HealthStorage) allCheckers() map[string]HealthChecker {
+	checkers := map[string]HealthChecker{}
+	for _, identification := range ext.GormV2.Identifications() {
+		id := identification
+		checkers["gorm:"+id] = func(ctx context.Context) error {
+			return ext.GormV2.Ping(ctx, id)
+		}
+	}
+	for _, identify := range db.Config.Identifications() {
+		id := identify
+		checkers["db:"+id] = func(ctx context.Context) error {
+			return db.Ping(ctx, id)
+		}
+	}
+	for _, identify := range ext.Redis.Identifications() {
+		id := identify
+		checkers["redis:"+id] = func(ctx context.Context) error {
+			return ext.Redis.Ping(id)
+		}
+	}
+	hs.lock.RLock()
+	defer hs.lock.RUnlock()
+	for name, checker := range hs.checkers {
+		checkers[name] = checker
+	}
+	return checkers
+}
+
+// 并发执行全部检查, timeout 为单项检查超时时间
+func (hs *HealthStorage) Check(timeout time.Duration) HealthReport {
+	startTime := time.Now()
+	checkers := hs.allCheckers()
+	names := make([]string, 0, len(checkers))
+	for name := range checkers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]HealthResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = runHealthChecker(name, checkers[name], timeout)
+		}(i, name)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthUp, Checks: results}
+	for _, result := range results {
+		if result.Status != HealthUp {
+			report.Status = HealthDown
+		}
+	}
+	report.Latency = milliseconds(time.Since(startTime))
+	return report
+}
+
+// 执行全部检查, ttl 内复用上次结果, 并发请求等待同一次检查完成
+func (hs *HealthStorage) CachedCheck(timeout time.Duration, ttl time.Duration) HealthReport {
+	hs.checkLock.Lock()
+	defer hs.checkLock.Unlock()
+	if ttl > 0 && !hs.cachedAt.IsZero() && time.Since(hs.cachedAt) < ttl {
+		return hs.cached
+	}
+	hs.cached, hs.cachedAt = hs.Check(timeout), time.Now()
+	return hs.cached
+}
+
+// 执行单项检查, 超时或 panic 视为失败
+func runHealthChecker(name string, checker HealthChecker, timeout time.Duration) HealthResult {
+	startTime := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	errChan := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- errors.New(fmt.Sprint(r))
+			}
+		}()
+		errChan <- checker(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+		err = errors.New(fmt.Sprintf("check timeout after %v", timeout))
+	}
+	result := HealthResult{Name: name, Status: HealthUp, Latency: milliseconds(time.Since(startTime))}
+	if err != nil {
+		result.Status = HealthDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// 耗时转毫秒, 保留3位小数
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
+
+// 注册健康检查接口, 未携带令牌时仅响应 up/down, 不暴露依赖地址及错误信息
+func (ws *WebServer) registerHealth() error {
+	option := healthOption()
+	if !option.Enable {
+		return nil
+	}
+	timeout := time.Duration(option.Timeout) * time.Millisecond
+	ttl := time.Duration(option.CacheTTL) * time.Millisecond
+	if option.LivePath != "" {
+		ws.gin.GET(option.LivePath, func(context *gin.Context) {
+			context.JSON(http.StatusOK, HealthReport{Status: HealthUp})
+		})
+	}
+	if option.HealthPath != "" {
+		ws.gin.GET(option.HealthPath, func(context *gin.Context) {
+			renderHealth(context, Health.CachedCheck(timeout, ttl), option.Token)
+		})
+	}
+	if option.ReadyPath != "" {
+		ws.gin.GET(option.ReadyPath, func(context *gin.Context) {
+			if Health.ShuttingDown() {
+				context.JSON(http.StatusServiceUnavailable, HealthReport{Status: HealthDown, ShuttingDown: true})
+				return
+			}
+			renderHealth(context, Health.CachedCheck(timeout, ttl), option.Token)
+		})
+	}
+	return nil
+}
+
+// 输出检查报告, 失败时响应 503, 令牌校验通过时输出各项检查详情
+func renderHealth(context *gin.Context, report HealthReport, token string) {
+	status := http.StatusOK
+	if report.Status != HealthUp {
+		status = http.StatusServiceUnavailable
+	}
+	if token == "" || !bearerAuthorized(context, token) {
+		report = HealthReport{Status: report.Status}
+	}
+	context.JSON(status, report)
+}
@@ -0,0 +1,109 @@
+package ratgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/utils/types"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck_01(t *testing.T) {
+	health := &HealthStorage{checkers: map[string]HealthChecker{}}
+	health.Register("ok", func(ctx context.Context) error { return nil })
+	health.Register("failed", func(ctx context.Context) error { return errors.New("connection refused") })
+	health.Register("panic", func(ctx context.Context) error { panic("nil pool") })
+	health.Register("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	startTime := time.Now()
+	report := health.Check(50 * time.Millisecond)
+	if time.Since(startTime) > 500*time.Millisecond {
+		t.Fatal(time.Since(startTime))
+	}
+	expect := map[string]string{"failed": "connection refused", "ok": "", "panic": "nil pool", "slow": "check timeout after 50ms"}
+	if report.Status != HealthDown || len(report.Checks) != len(expect) {
+		t.Fatal(report)
+	}
+	for _, result := range report.Checks {
+		if result.Error != expect[result.Name] || (result.Error == "") != (result.Status == HealthUp) {
+			t.Errorf("%v", result)
+		}
+	}
+}
+
+func TestHealthHandle_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer func(health *HealthStorage, appConfig types.AnyMap) {
+		Health, Config.appConfig = health, appConfig
+	}(Health, Config.appConfig)
+	Health = &HealthStorage{checkers: map[string]HealthChecker{}}
+	Config.appConfig = types.AnyMap{"health": map[string]interface{}{"Enable": true, "Token": "secret", "CacheTTL": 60000}}
+	var calls int32
+	var failed atomic.Value
+	failed.Store(false)
+	Health.Register("redis:cache", func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		if failed.Load().(bool) {
+			return errors.New("dial tcp 10.0.0.1:6379: connection refused")
+		}
+		return nil
+	})
+	ws := &WebServer{gin: gin.New()}
+	_ = ws.registerHealth()
+	request := func(path string, header map[string]string) (int, map[string]interface{}) {
+		recorder := testServe(ws.gin, http.MethodGet, path, header)
+		data := map[string]interface{}{}
+		_ = json.Unmarshal(recorder.Body.Bytes(), &data)
+		return recorder.Code, data
+	}
+
+	// 未携带令牌时仅响应状态, 结果缓存期间不重复检查
+	failed.Store(true)
+	for _, path := range []string{"/healthz", "/readyz", "/healthz"} {
+		if code, data := request(path, nil); code != http.StatusServiceUnavailable || len(data) != 1 || data["status"] != HealthDown {
+			t.Fatal(path, code, data)
+		}
+	}
+	if calls != 1 {
+		t.Fatal(calls)
+	}
+	for _, token := range []string{"Bearer secreT", "secret", "Bearer secret "} {
+		if _, data := request("/healthz", map[string]string{"Authorization": token}); data["checks"] != nil {
+			t.Fatal(token, data)
+		}
+	}
+	code, data := request("/healthz", map[string]string{"Authorization": "Bearer secret"})
+	checks, _ := data["checks"].([]interface{})
+	if code != http.StatusServiceUnavailable || len(checks) != 1 || checks[0].(map[string]interface{})["error"] != "dial tcp 10.0.0.1:6379: connection refused" {
+		t.Fatal(code, data)
+	}
+
+	// 缓存过期后重新检查
+	failed.Store(false)
+	Health.cachedAt = time.Now().Add(-time.Minute)
+	if code, data := request("/readyz", nil); code != http.StatusOK || data["status"] != HealthUp || calls != 2 {
+		t.Fatal(code, data, calls)
+	}
+	if code, data := request("/livez", nil); code != http.StatusOK || len(data) != 1 {
+		t.Fatal(code, data)
+	}
+
+	// 服务关闭中就绪检查失败, 存活检查不受影响
+	Health.SetShuttingDown()
+	if code, data := request("/readyz", nil); code != http.StatusServiceUnavailable || data["shuttingDown"] != true {
+		t.Fatal(code, data)
+	}
+	if code, _ := request("/healthz", nil); code != http.StatusOK {
+		t.Fatal(code)
+	}
+	if code, _ := request("/livez", nil); code != http.StatusOK {
+		t.Fatal(code)
+	}
+}
@@ -25,9 +25,12 @@ import (
 // 注册日志中间件
 func RegisterLogMiddleWare() error {
 	MiddleWare.SetGlobal(func(context *gin.Context) {
-		requestTime := time.Now()                                                       // 请求时间
-		requestUri := context.Request.RequestURI                                        // 请求路径
-		requestId := encrypt.Md5(requestUri, fmt.Sprintf("%d", requestTime.UnixNano())) // 生成 requestId
+		requestTime := time.Now()                // 请求时间
+		requestUri := context.Request.RequestURI // 请求路径
+		requestId := context.GetString("requestId")
+		if requestId == "" { // 未经过链路追踪中间件时生成 requestId
+			requestId = encrypt.Md5(requestUri, fmt.Sprintf("%d", requestTime.UnixNano()))
+		}
 
 		// 构造入参数据
 		request := map[string]interface{}{
@@ -0,0 +1,97 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/ext"
+	"github.com/vdongchina/ratgo/extend/db"
+	"github.com/vdongchina/ratgo/utils/metrics"
+	"net/http"
+	"time"
+)
+
+// 监控指标配置, 对应 ratgo 配置 metrics 节点
+type MetricsOption struct {
+	Enable bool
+	Path   string // 指标接口路径, 默认 /metrics
+	Token  string // 访问令牌, 请求头 Authorization: Bearer <Token>, 为空时不校验
+}
+
+// 监控指标中间件优先级, 在链路追踪之后、其他中间件之前执行
+const metricsMiddleWarePriority = 999
+
+// 未匹配路由的 route 标签, 避免按请求路径产生大量时间序列
+const unmatchedRoute = "unmatched"
+
+// 读取监控指标配置
+func metricsOption() MetricsOption {
+	option := MetricsOption{Path: "/metrics"}
+	loadOption(&option, Config.GetApp("metrics").ToAnyMap())
+	return option
+}
+
+// 注册监控指标中间件及连接池采集, 配置 metrics.Enable 为 true 时生效
+func RegisterMetricsMiddleWare() error {
+	if !metricsOption().Enable {
+		return nil
+	}
+	metrics.RegisterSQLSource("gorm", ext.GormV2.SqlDBs)
+	metrics.RegisterSQLSource("dao", db.Query.SqlDBs)
+	metrics.RegisterRedisSource("redis", func() map[string]metrics.RedisPool {
+		pools := map[string]metrics.RedisPool{}
+		for identify, pool := range ext.Redis.Pools() {
+			pools[identify] = pool
+		}
+		return pools
+	})
+	metrics.RegisterRedisSource("sentinel", func() map[string]metrics.RedisPool {
+		pools := map[string]metrics.RedisPool{}
+		for identify, pool := range ext.Redis.SentinelPools() {
+			pools[identify] = pool
+		}
+		return pools
+	})
+	MiddleWare.SetGlobalPriority(metricsMiddleWarePriority, MetricsMiddleWare())
+	return nil
+}
+
+// 监控指标中间件, 按请求方法、路由记录请求数及耗时, 简易路由使用控制器路径, Restful路由使用注册路径
+func MetricsMiddleWare() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		startTime := time.Now()
+		context.Next()
+		route := context.GetString("generalPath")
+		if route == "" {
+			route = context.FullPath()
+		}
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.ObserveHTTP(context.Request.Method, route, context.Writer.Status(), time.Since(startTime))
+	}
+}
+
+// 注册指标接口
+func (ws *WebServer) registerMetrics() error {
+	option := metricsOption()
+	if !option.Enable || option.Path == "" {
+		return nil
+	}
+	handler := metrics.Handler()
+	ws.gin.GET(option.Path, func(context *gin.Context) {
+		if option.Token != "" && !bearerAuthorized(context, option.Token) {
+			context.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(context.Writer, context.Request)
+	})
+	return nil
+}
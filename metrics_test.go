@@ -0,0 +1,54 @@
+package ratgo
+
+import (
+	"github.com/vdongchina/ratgo/utils/types"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetricsMiddleWare_01(t *testing.T) {
+	ws, restore := testWebServer()
+	defer restore()
+	defer func(appConfig types.AnyMap) { Config.appConfig = appConfig }(Config.appConfig)
+	Config.appConfig = types.AnyMap{"metrics": map[string]interface{}{"Enable": true, "Path": "/_metrics", "Token": "secret"}}
+	MiddleWare.SetGlobalPriority(metricsMiddleWarePriority, MetricsMiddleWare())
+	Router.General("mtest", GeneralMap{"user/info": &echoController{}})
+	Router.GET("/mtest/users/:id", &echoController{})
+	_ = ws.registerMiddleWare()
+	_ = ws.registerRouter()
+	_ = ws.registerMetrics()
+
+	testServe(ws.gin, http.MethodGet, "/mtest/user/info", nil)
+	testServe(ws.gin, http.MethodPut, "/mtest/user/info", nil)
+	testServe(ws.gin, http.MethodGet, "/mtest/users/1", nil)
+	testServe(ws.gin, http.MethodGet, "/mtest/users/2", nil)
+	testServe(ws.gin, http.MethodGet, "/mtest/missing/a/b/c", nil)
+
+	// 令牌校验
+	for _, token := range []string{"", "secret", "Bearer secreT"} {
+		if code := testServe(ws.gin, http.MethodGet, "/_metrics", map[string]string{"Authorization": token}).Code; code != http.StatusUnauthorized {
+			t.Fatal(token, code)
+		}
+	}
+	recorder := testServe(ws.gin, http.MethodGet, "/_metrics", map[string]string{"Authorization": "Bearer secret"})
+	if recorder.Code != http.StatusOK {
+		t.Fatal(recorder.Code)
+	}
+
+	// 简易路由使用控制器路径, Restful路由使用注册路径, 未匹配路由合并为 unmatched
+	body := recorder.Body.String()
+	for _, line := range []string{
+		`ratgo_http_requests_total{method="GET",route="/mtest/user/info",status="200"} 1`,
+		`ratgo_http_requests_total{method="PUT",route="/mtest/user/info",status="405"} 1`,
+		`ratgo_http_requests_total{method="GET",route="/mtest/users/:id",status="200"} 2`,
+		`ratgo_http_requests_total{method="GET",route="unmatched",status="404"}`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("metrics expect '%s'", line)
+		}
+	}
+	if strings.Contains(body, "/mtest/users/1") || strings.Contains(body, "/mtest/missing") {
+		t.Fatal(body)
+	}
+}
@@ -11,12 +11,46 @@ package ratgo
 
 import (
 	"github.com/gin-gonic/gin"
+	"sort"
+	"strings"
 )
 
+// 中间件项, priority越大越先执行, 相同优先级按注册顺序执行
+type middleWareItem struct {
+	priority    int
+	handlerFunc gin.HandlerFunc
+}
+
+// 中间件列表
+type middleWareList []middleWareItem
+
+// 添加中间件
+func (mwl middleWareList) add(priority int, handlerFunc ...gin.HandlerFunc) middleWareList {
+	for _, v := range handlerFunc {
+		mwl = append(mwl, middleWareItem{priority: priority, handlerFunc: v})
+	}
+	return mwl
+}
+
+// 按优先级排序后的 handler
+func (mwl middleWareList) handlers() []gin.HandlerFunc {
+	sorted := make(middleWareList, len(mwl))
+	copy(sorted, mwl)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].priority > sorted[j].priority
+	})
+	handlerFuncSlice := make([]gin.HandlerFunc, 0, len(sorted))
+	for _, v := range sorted {
+		handlerFuncSlice = append(handlerFuncSlice, v.handlerFunc)
+	}
+	return handlerFuncSlice
+}
+
 // 中间件容器
 type MiddleWareStorage struct {
-	global []gin.HandlerFunc
-	group  map[string][]gin.HandlerFunc
+	global middleWareList
+	group  map[string]middleWareList
+	route  map[string]middleWareList
 }
 
 // 初始化
@@ -24,37 +58,88 @@ var MiddleWare *MiddleWareStorage
 
 func init() {
 	MiddleWare = &MiddleWareStorage{
-		global: make([]gin.HandlerFunc, 0),
-		group:  map[string][]gin.HandlerFunc{},
+		global: make(middleWareList, 0),
+		group:  map[string]middleWareList{},
+		route:  map[string]middleWareList{},
 	}
 }
 
 // 设置全局中间件
 func (mws *MiddleWareStorage) SetGlobal(handlerFunc ...gin.HandlerFunc) {
-	mws.global = append(mws.global, handlerFunc...)
+	mws.SetGlobalPriority(0, handlerFunc...)
+}
+
+// 设置全局中间件并指定优先级
+func (mws *MiddleWareStorage) SetGlobalPriority(priority int, handlerFunc ...gin.HandlerFunc) {
+	mws.global = mws.global.add(priority, handlerFunc...)
 }
 
 // 批量设置全局中间件
 func (mws *MiddleWareStorage) BatchSetGlobal(handlerFuncSlice []gin.HandlerFunc) {
-	mws.global = append(mws.global, handlerFuncSlice...)
+	mws.SetGlobalPriority(0, handlerFuncSlice...)
 }
 
 // 获取全局中间件
 func (mws *MiddleWareStorage) GetGlobal() []gin.HandlerFunc {
-	return mws.global
+	return mws.global.handlers()
 }
 
-// 设置分组中间件
+// 设置分组中间件, groupName对应路由分组(如 admin 作用于 /admin/*)
 func (mws *MiddleWareStorage) SetGroup(groupName string, handlerFunc ...gin.HandlerFunc) {
-	mws.group[groupName] = append(mws.group[groupName], handlerFunc...)
+	mws.SetGroupPriority(groupName, 0, handlerFunc...)
+}
+
+// 设置分组中间件并指定优先级
+func (mws *MiddleWareStorage) SetGroupPriority(groupName string, priority int, handlerFunc ...gin.HandlerFunc) {
+	groupName = strings.Trim(groupName, "/")
+	mws.group[groupName] = mws.group[groupName].add(priority, handlerFunc...)
 }
 
 // 批量设置分组中间件
 func (mws *MiddleWareStorage) BatchSetGroup(groupName string, handlerFuncSlice []gin.HandlerFunc) {
-	mws.group[groupName] = append(mws.group[groupName], handlerFuncSlice...)
+	mws.SetGroupPriority(groupName, 0, handlerFuncSlice...)
 }
 
 // 获取分组组中间件
 func (mws *MiddleWareStorage) GetGroup() map[string][]gin.HandlerFunc {
-	return mws.group
+	groupMap := map[string][]gin.HandlerFunc{}
+	for key, value := range mws.group {
+		groupMap[key] = value.handlers()
+	}
+	return groupMap
+}
+
+// 获取分组链中间件, 如 api/v1 依次获取 api、api/v1 分组中间件
+func (mws *MiddleWareStorage) GetGroupChain(groupName string) []gin.HandlerFunc {
+	handlerFuncSlice := make([]gin.HandlerFunc, 0)
+	groupName = strings.Trim(groupName, "/")
+	if groupName == "" {
+		return handlerFuncSlice
+	}
+	segments := strings.Split(groupName, "/")
+	for i := 1; i <= len(segments); i++ {
+		handlerFuncSlice = append(handlerFuncSlice, mws.group[strings.Join(segments[:i], "/")].handlers()...)
+	}
+	return handlerFuncSlice
+}
+
+// 设置路由中间件, path为简易路由完整路径(如 /admin/user/list)或Restful路由路径(如 /api/users/:id)
+func (mws *MiddleWareStorage) SetRoute(path string, handlerFunc ...gin.HandlerFunc) {
+	mws.SetRoutePriority(path, 0, handlerFunc...)
+}
+
+// 设置路由中间件并指定优先级
+func (mws *MiddleWareStorage) SetRoutePriority(path string, priority int, handlerFunc ...gin.HandlerFunc) {
+	path = joinPath("", path)
+	mws.route[path] = mws.route[path].add(priority, handlerFunc...)
+}
+
+// 批量设置路由中间件
+func (mws *MiddleWareStorage) BatchSetRoute(path string, handlerFuncSlice []gin.HandlerFunc) {
+	mws.SetRoutePriority(path, 0, handlerFuncSlice...)
+}
+
+// 获取路由中间件
+func (mws *MiddleWareStorage) GetRoute(path string) []gin.HandlerFunc {
+	return mws.route[joinPath("", path)].handlers()
 }
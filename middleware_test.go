@@ -0,0 +1,108 @@
+package ratgo
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// 记录中间件执行顺序
+var middleWareCalls []string
+
+func testMark(name string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		middleWareCalls = append(middleWareCalls, name)
+	}
+}
+
+// 依次执行 handler 并返回执行顺序
+func testRun(handlerFuncSlice []gin.HandlerFunc) []string {
+	middleWareCalls = []string{}
+	for _, handlerFunc := range handlerFuncSlice {
+		handlerFunc(nil)
+	}
+	return middleWareCalls
+}
+
+func TestMiddleWarePriority_01(t *testing.T) {
+	_, restore := testWebServer()
+	defer restore()
+
+	// 优先级高的先执行, 相同优先级按注册顺序
+	MiddleWare.SetGlobal(testMark("a"))
+	MiddleWare.SetGlobalPriority(10, testMark("b"), testMark("c"))
+	MiddleWare.BatchSetGlobal([]gin.HandlerFunc{testMark("d")})
+	MiddleWare.SetGlobalPriority(999, testMark("metrics"))
+	MiddleWare.SetGlobalPriority(-1, testMark("last"))
+	MiddleWare.SetGlobalPriority(900, testMark("compress"))
+	if calls := testRun(MiddleWare.GetGlobal()); !reflect.DeepEqual(calls, []string{"metrics", "compress", "b", "c", "a", "d", "last"}) {
+		t.Fatal(calls)
+	}
+
+	// 分组链: 父分组在前, 各分组内按优先级排序
+	MiddleWare.SetGroup("api", testMark("api"))
+	MiddleWare.SetGroup("/api/v1/", testMark("v1"))
+	MiddleWare.SetGroupPriority("api/v1", 5, testMark("v1-auth"))
+	MiddleWare.SetGroup("apix", testMark("apix"))
+	cases := map[string][]string{
+		"":           {},
+		"api":        {"api"},
+		"/api/v1":    {"api", "v1-auth", "v1"},
+		"api/v1/foo": {"api", "v1-auth", "v1"},
+		"apix":       {"apix"},
+	}
+	for group, expect := range cases {
+		if calls := testRun(MiddleWare.GetGroupChain(group)); !reflect.DeepEqual(calls, expect) {
+			t.Errorf("group '%s' expect %v, but receive %v", group, expect, calls)
+		}
+	}
+
+	// 路由中间件
+	MiddleWare.SetRoute("/api/v1/users/:id", testMark("route"))
+	MiddleWare.SetRoutePriority("api/v1/users/:id/", 1, testMark("route-first"))
+	if calls := testRun(MiddleWare.GetRoute("/api/v1/users/:id")); !reflect.DeepEqual(calls, []string{"route-first", "route"}) {
+		t.Fatal(calls)
+	}
+}
+
+// 记录控制器执行
+type markController struct {
+	Controller
+}
+
+func (mc *markController) Exec() {
+	middleWareCalls = append(middleWareCalls, "controller")
+	mc.Result().SetType(RespString)
+}
+
+func TestMiddleWareChain_01(t *testing.T) {
+	ws, restore := testWebServer()
+	defer restore()
+	MiddleWare.SetGlobal(testMark("global"))
+	MiddleWare.SetGroup("api", testMark("api"))
+	MiddleWare.SetGroup("api/v1", testMark("v1"))
+	MiddleWare.SetGroup("admin", testMark("admin"))
+	MiddleWare.SetRoute("/api/v1/users/:id", testMark("route"))
+	MiddleWare.SetRoute("/admin/user/info", testMark("general-route"))
+	Router.Group("api", func(group *RestfulGroup) {
+		group.Group("v1", func(group *RestfulGroup) {
+			group.GET("users/:id", &markController{}, testMark("handler"))
+		}, testMark("group-handler"))
+	})
+	Router.General("admin", GeneralMap{"user/info": &markController{}})
+	_ = ws.registerMiddleWare()
+	_ = ws.registerRouter()
+
+	// 全局 → 父分组 → 子分组 → 注册时指定的中间件 → SetRoute → 控制器
+	cases := map[string][]string{
+		"/api/v1/users/1":  {"global", "api", "v1", "group-handler", "handler", "route", "controller"},
+		"/admin/user/info": {"global", "admin", "general-route", "controller"},
+	}
+	for path, expect := range cases {
+		middleWareCalls = []string{}
+		if code := testServe(ws.gin, http.MethodGet, path, nil).Code; code != http.StatusOK || !reflect.DeepEqual(middleWareCalls, expect) {
+			t.Errorf("'%s' expect %v, but receive %d %v", path, expect, code, middleWareCalls)
+		}
+	}
+}
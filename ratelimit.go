@@ -0,0 +1,275 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/ext"
+	"github.com/vdongchina/ratgo/utils/vdlog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 限流配置, 对应 ratgo 配置 rateLimit 节点
+type RateLimitOption struct {
+	Enable  bool
+	Limit   int      // 每个周期生成的令牌数
+	Period  int      // 周期(秒)
+	Burst   int      // 令牌桶容量, 默认等于 Limit
+	KeyBy   []string // 限流维度: ip、route、header:X-Api-Key, 多个组合使用
+	Store   string   // 存储: memory | redis
+	Redis   string   // redis配置标识, 对应 ext.Redis.Pool(identify)
+	Prefix  string   // redis key前缀
+	MaxKeys int      // 内存存储最多保存的令牌桶数量, 超出时淘汰最久未使用的
+}
+
+// 令牌桶存储
+type RateLimitStore interface {
+	// 获取一个令牌, rate 为每秒生成令牌数, 返回是否允许、剩余令牌数、距下个令牌的等待时间
+	Take(key string, rate float64, burst int) (allowed bool, remaining int, retryAfter time.Duration, err error)
+}
+
+// 默认限流配置
+func DefaultRateLimitOption() RateLimitOption {
+	return RateLimitOption{
+		Limit:   100,
+		Period:  1,
+		KeyBy:   []string{"ip"},
+		Store:   "memory",
+		Prefix:  "ratgo:ratelimit:",
+		MaxKeys: defaultRateLimitMaxKeys,
+	}
+}
+
+// 限流中间件, 超出时响应 429, 存储异常时放行并记录日志
+func RateLimitMiddleWare(option RateLimitOption) (gin.HandlerFunc, error) {
+	if option.Limit <= 0 || option.Period <= 0 {
+		return nil, errors.New(fmt.Sprintf("rate limit 'Limit' and 'Period' must be positive, got %d/%d", option.Limit, option.Period))
+	}
+	if option.Burst <= 0 {
+		option.Burst = option.Limit
+	}
+	var store RateLimitStore
+	switch option.Store {
+	case "", "memory":
+		store = NewMemoryRateLimitStore(option.MaxKeys)
+	case "redis":
+		if option.Redis == "" {
+			return nil, errors.New("rate limit redis store requires 'Redis' identify")
+		}
+		store = NewRedisRateLimitStore(ext.Redis.Pool(option.Redis), option.Prefix)
+	default:
+		return nil, errors.New(fmt.Sprintf("unsupported rate limit store '%s'", option.Store))
+	}
+	return RateLimitWithStore(option, store), nil
+}
+
+// 使用自定义存储的限流中间件
+func RateLimitWithStore(option RateLimitOption, store RateLimitStore) gin.HandlerFunc {
+	rate := float64(option.Limit) / float64(option.Period)
+	if option.Burst <= 0 {
+		option.Burst = option.Limit
+	}
+	limit := strconv.Itoa(option.Burst)
+	return func(context *gin.Context) {
+		allowed, remaining, retryAfter, err := store.Take(rateLimitKey(context, option.KeyBy), rate, option.Burst)
+		if err != nil {
+			vdlog.StdLogger.Error(fmt.Sprintf("rate limit store error: %v", err))
+			context.Next()
+			return
+		}
+		context.Header("X-RateLimit-Limit", limit)
+		context.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !allowed {
+			context.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			context.Abort()
+			Render(context, NewResult().SetStatus(http.StatusTooManyRequests).SetMsg("Too Many Requests"))
+			return
+		}
+		context.Next()
+	}
+}
+
+// 限流key, header 不存在时使用客户端ip, 未配置 TrustedProxies 时客户端ip为连接地址
+func rateLimitKey(context *gin.Context, keyBy []string) string {
+	if len(keyBy) == 0 {
+		keyBy = []string{"ip"}
+	}
+	keySlice := make([]string, 0, len(keyBy))
+	for _, by := range keyBy {
+		switch {
+		case by == "ip":
+			keySlice = append(keySlice, clientIP(context))
+		case by == "route":
+			keySlice = append(keySlice, context.Request.Method+" "+context.Request.URL.Path)
+		case strings.HasPrefix(by, "header:"):
+			if value := context.GetHeader(strings.TrimPrefix(by, "header:")); value != "" {
+				keySlice = append(keySlice, value)
+			} else {
+				keySlice = append(keySlice, clientIP(context))
+			}
+		}
+	}
+	return strings.Join(keySlice, "|")
+}
+
+/**************************************** 内存存储 ****************************************/
+// 内存存储默认最多保存的令牌桶数量
+const defaultRateLimitMaxKeys = 100000
+
+// 令牌桶
+type tokenBucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// 内存令牌桶存储, 单进程有效, 超出 maxKeys 时淘汰最久未使用的令牌桶
+type MemoryRateLimitStore struct {
+	sync.Mutex
+	buckets   map[string]*list.Element
+	order     *list.List // 按使用时间排序, 最近使用的在前
+	maxKeys   int
+	lastClean time.Time
+}
+
+// 创建内存令牌桶存储, maxKeys 小于等于0时使用默认值
+func NewMemoryRateLimitStore(maxKeys int) *MemoryRateLimitStore {
+	if maxKeys <= 0 {
+		maxKeys = defaultRateLimitMaxKeys
+	}
+	return &MemoryRateLimitStore{buckets: map[string]*list.Element{}, order: list.New(), maxKeys: maxKeys, lastClean: time.Now()}
+}
+
+// 获取令牌
+func (ms *MemoryRateLimitStore) Take(key string, rate float64, burst int) (bool, int, time.Duration, error) {
+	ms.Lock()
+	defer ms.Unlock()
+	now := time.Now()
+	ms.clean(now, rate, burst)
+
+	element, ok := ms.buckets[key]
+	if ok {
+		ms.order.MoveToFront(element)
+	} else {
+		if ms.order.Len() >= ms.maxKeys { // 淘汰最久未使用的令牌桶
+			ms.remove(ms.order.Back())
+		}
+		element = ms.order.PushFront(&tokenBucket{key: key, tokens: float64(burst), last: now})
+		ms.buckets[key] = element
+	}
+	bucket := element.Value.(*tokenBucket)
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false, 0, time.Duration((1 - bucket.tokens) / rate * float64(time.Second)), nil
+	}
+	bucket.tokens--
+	return true, int(bucket.tokens), 0, nil
+}
+
+// 令牌桶数量
+func (ms *MemoryRateLimitStore) Len() int {
+	ms.Lock()
+	defer ms.Unlock()
+	return ms.order.Len()
+}
+
+// 每分钟清理已填满的令牌桶
+func (ms *MemoryRateLimitStore) clean(now time.Time, rate float64, burst int) {
+	if now.Sub(ms.lastClean) < time.Minute {
+		return
+	}
+	ms.lastClean = now
+	for element := ms.order.Back(); element != nil; {
+		prev := element.Prev()
+		if bucket := element.Value.(*tokenBucket); bucket.tokens+now.Sub(bucket.last).Seconds()*rate >= float64(burst) {
+			ms.remove(element)
+		}
+		element = prev
+	}
+}
+
+// 移除令牌桶
+func (ms *MemoryRateLimitStore) remove(element *list.Element) {
+	ms.order.Remove(element)
+	delete(ms.buckets, element.Value.(*tokenBucket).key)
+}
+
+/**************************************** redis存储 ****************************************/
+// 令牌桶脚本, 返回 {是否允许, 剩余令牌数}
+var rateLimitScript = redis.NewScript(1, `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+
+// redis令牌桶存储, 多实例共享
+type RedisRateLimitStore struct {
+	pool   *redis.Pool
+	prefix string
+}
+
+// 创建redis令牌桶存储
+func NewRedisRateLimitStore(pool *redis.Pool, prefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{pool: pool, prefix: prefix}
+}
+
+// 获取令牌
+func (rs *RedisRateLimitStore) Take(key string, rate float64, burst int) (bool, int, time.Duration, error) {
+	conn := rs.pool.Get()
+	defer func() { _ = conn.Close() }()
+
+	ttl := int64(math.Ceil(float64(burst)/rate*1000)) * 2 // 令牌桶填满时间的两倍
+	reply, err := redis.Values(rateLimitScript.Do(conn, rs.prefix+key, rate, burst, time.Now().UnixNano()/int64(time.Millisecond), ttl))
+	if err != nil {
+		return false, 0, 0, err
+	}
+	if len(reply) != 2 {
+		return false, 0, 0, errors.New(fmt.Sprintf("unexpected rate limit reply: %v", reply))
+	}
+	allowed, err := redis.Int(reply[0], nil)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	tokens, err := redis.Float64(reply[1], nil)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	if allowed == 0 {
+		return false, 0, time.Duration((1 - tokens) / rate * float64(time.Second)), nil
+	}
+	return true, int(tokens), 0, nil
+}
@@ -0,0 +1,94 @@
+package ratgo
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStore_01(t *testing.T) {
+	store := NewMemoryRateLimitStore(0)
+	for i := 0; i < 3; i++ {
+		allowed, remaining, _, err := store.Take("ip", 10, 3)
+		if err != nil || !allowed || remaining != 2-i {
+			t.Fatal(i, allowed, remaining, err)
+		}
+	}
+	allowed, _, retryAfter, _ := store.Take("ip", 10, 3)
+	if allowed || retryAfter <= 0 || retryAfter > 100*time.Millisecond {
+		t.Fatal(allowed, retryAfter)
+	}
+
+	// 不同 key 互不影响
+	if allowed, _, _, _ := store.Take("other", 10, 3); !allowed {
+		t.FailNow()
+	}
+
+	// 按速率补充令牌
+	time.Sleep(150 * time.Millisecond)
+	if allowed, _, _, _ := store.Take("ip", 10, 3); !allowed {
+		t.FailNow()
+	}
+}
+
+func TestMemoryRateLimitStore_02(t *testing.T) {
+	store := NewMemoryRateLimitStore(3)
+	for i := 0; i < 3; i++ {
+		_, _, _, _ = store.Take(fmt.Sprint(i), 1, 1)
+	}
+	_, _, _, _ = store.Take("0", 1, 1) // 0 最近使用, 1 最久未使用
+
+	// 超出容量时淘汰最久未使用的令牌桶
+	_, _, _, _ = store.Take("3", 1, 1)
+	if store.Len() != 3 {
+		t.Fatal(store.Len())
+	}
+	if allowed, _, _, _ := store.Take("1", 1, 1); !allowed {
+		t.FailNow()
+	}
+	if allowed, _, _, _ := store.Take("0", 1, 1); allowed {
+		t.FailNow()
+	}
+}
+
+func TestRateLimitKey_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RateLimitWithStore(RateLimitOption{Limit: 1, Period: 60, KeyBy: []string{"ip"}}, NewMemoryRateLimitStore(0)))
+	engine.GET("/", func(context *gin.Context) {
+		context.String(http.StatusOK, "ok")
+	})
+	request := func(forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.9:5678"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+	defer func(trustedProxies string) { Config.TrustedProxies = trustedProxies }(Config.TrustedProxies)
+
+	// 未配置可信代理时更换 X-Forwarded-For 无法绕过限流
+	Config.TrustedProxies = ""
+	if code := request("10.0.0.1"); code != http.StatusOK {
+		t.Fatal(code)
+	}
+	if code := request("10.0.0.2"); code != http.StatusTooManyRequests {
+		t.Fatal(code)
+	}
+
+	// 配置可信代理后按 X-Forwarded-For 限流
+	Config.TrustedProxies = "203.0.113.0/24"
+	if err := setTrustedProxies(engine); err != nil {
+		t.Fatal(err)
+	}
+	if code := request("10.0.0.3"); code != http.StatusOK {
+		t.Fatal(code)
+	}
+	if code := request("10.0.0.3"); code != http.StatusTooManyRequests {
+		t.Fatal(code)
+	}
+}
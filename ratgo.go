@@ -9,36 +9,268 @@
 // limitations under the License.
 package ratgo
 
-// 应用容器
-var AppStorage map[string]interface{}
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/vdongchina/ratgo/ext"
+	"github.com/vdongchina/ratgo/extend"
+	"github.com/vdongchina/ratgo/extend/cache"
+	"github.com/vdongchina/ratgo/utils/trace"
+	"github.com/vdongchina/ratgo/utils/types"
+	"github.com/vdongchina/ratgo/utils/vdlog"
+	"os"
+)
 
-// 初始化
+// 初始化, 注册内置服务
 func init() {
-	AppStorage = map[string]interface{}{}
+	AppStorage = NewContainer()
+	_ = AppStorage.Singleton("config", func() *ConfigStorage { return Config })
+	_ = AppStorage.Singleton("gormV2", func() *ext.DbStorage { return ext.GormV2 })
+	_ = AppStorage.Singleton("gorm", func() *extend.DbStorage { return extend.Gorm })
+	_ = AppStorage.Singleton("redis", func() *ext.RedisCache { return ext.Redis })
+	_ = AppStorage.Singleton("cacheRedis", func() *cache.RedisCache { return cache.Redis })
+	_ = AppStorage.Transient("logger", func(ctx *gin.Context) *vdlog.Logger { // 请求内为设置了 requestId 的日志对象
+		if ctx != nil {
+			if logger, ok := ctx.Get("logger"); ok {
+				return logger.(*vdlog.Logger)
+			}
+		}
+		return vdlog.StdLogger
+	})
+	AppStorage.Provider("db", func(key string) (instance interface{}, err error) { // 例: inject:"db.plus_center.master"
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverError(r)
+			}
+		}()
+		return ext.GormV2.GormDB(key), nil
+	})
+	AppStorage.Provider("redis", func(key string) (instance interface{}, err error) { // 例: inject:"redis.plus_center.master"
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverError(r)
+			}
+		}()
+		return ext.Redis.Pool(key), nil
+	})
+}
+
+// panic 信息转换为 error
+func recoverError(r interface{}) error {
+	switch value := r.(type) {
+	case error:
+		return value
+	case *logrus.Entry:
+		return errors.New(value.Message)
+	default:
+		return errors.New(fmt.Sprint(r))
+	}
 }
 
 // 运行web服务
 func RunWebServer() {
-	webServer := NewWebServer()         // 获取WebServer指针
-	AppStorage["WebServer"] = webServer // 存储WebServer
-	webServer.Init()                    // WebServer初始化
-	webServer.Run()                     // 运行WebServer
+	webServer := NewWebServer()            // 获取WebServer指针
+	AppStorage.Set("WebServer", webServer) // 存储WebServer
+	webServer.Init()                       // WebServer初始化
+	webServer.Run()                        // 运行WebServer
 }
 
 // 获取 *WebServer
 func GetWebServer() *WebServer {
-	if webServer, ok := AppStorage["WebServer"]; ok {
-		return webServer.(*WebServer)
+	if webServer, ok := AppStorage.Get("WebServer").(*WebServer); ok {
+		return webServer
 	}
 	return nil
 }
 
 // 运行cmd服务
 func RunCmd() {
-	// ...
+	cmdServer := NewCmdServer()            // 获取CmdServer指针
+	AppStorage.Set("CmdServer", cmdServer) // 存储CmdServer
+	args := cmdArgs()
+
+	// help、命令用法及未定义命令无需初始化
+	handled, err := cmdServer.Help(args)
+	if !handled {
+		cmdServer.Init()           // CmdServer初始化
+		runOnStart()               // 执行服务启动函数
+		err = cmdServer.Run(args)  // 运行命令
+		runOnShutdown("CmdServer") // 执行服务关闭函数并释放资源
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "[CmdServer]%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// 命令行参数, 去除配置参数 --set
+func cmdArgs() []string {
+	_, args := splitConfigFlags(os.Args[1:])
+	return args
+}
+
+// 获取 *CmdServer
+func GetCmdServer() *CmdServer {
+	if cmdServer, ok := AppStorage.Get("CmdServer").(*CmdServer); ok {
+		return cmdServer
+	}
+	return nil
 }
 
 // 运行websocket服务
 func RunWebsocket() {
-	// ...
+	websocketServer := NewWebsocketServer()            // 获取WebsocketServer指针
+	AppStorage.Set("WebsocketServer", websocketServer) // 存储WebsocketServer
+	websocketServer.Init()                             // WebsocketServer初始化
+	websocketServer.Run()                              // 运行WebsocketServer
+}
+
+// 获取 *WebsocketServer
+func GetWebsocketServer() *WebsocketServer {
+	if websocketServer, ok := AppStorage.Get("WebsocketServer").(*WebsocketServer); ok {
+		return websocketServer
+	}
+	return nil
+}
+
+// 配置初始化
+func initConfig(tag string) {
+	fmt.Printf("[%s]系统配置初始化...\r\n", tag)
+	Config.Init()
+
+	// 状态码映射
+	if err := parseStatusMapping(Config.StatusMapping); err != nil {
+		panic(err)
+	}
+
+	// 配置处理
+	if Config.HandleFunc != nil {
+		fmt.Printf("[%s]系统配置经过应用处理...\r\n", tag)
+		err := Config.HandleFunc(Config)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// 初始化 mysql、redis
+func initResource(tag string) {
+	// 初始化 mysql
+	if Config.InitDb == true {
+		dbConfig := Config.Get("database").ToAnyMap()
+		fmt.Printf("[%s]数据库对象 config: %v \r\n", tag, Config.Masked("database"))
+		extend.Gorm.Init(dbConfig)
+		ext.GormV2.Init(dbConfig)
+	}
+
+	// 初始化 redis
+	if Config.InitRedis == true {
+		redisConfig := Config.Get("redis").ToAnyMap()
+		fmt.Printf("[%s]redis对象 config: %v \r\n", tag, Config.Masked("redis"))
+		cache.Redis.Init(redisConfig)
+		ext.Redis.Init(redisConfig)
+	}
+
+	// 校验数据库、redis配置, 启动时列出全部错误
+	if err := validateResource(); err != nil {
+		panic(err.Error())
+	}
+
+	// 初始化链路追踪
+	initTracer(tag)
+}
+
+// 校验已初始化的数据库、redis配置
+func validateResource() error {
+	errs := types.UnmarshalErrors{}
+	if Config.InitDb == true {
+		if err := ext.GormV2.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if Config.InitRedis == true {
+		if err := ext.Redis.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(fmt.Sprintf("invalid config: %v", errs))
+	}
+	return nil
+}
+
+// 初始化系统日志, 返回日志是否开启
+func initLogger() bool {
+	logConfig, ok := loggerConfig()
+	if !ok {
+		return false
+	}
+	_ = vdlog.Use(map[string]interface{}(logConfig)) // 更新全局 StdLogger
+	return true
+}
+
+// 读取系统日志配置, 返回日志是否开启
+func loggerConfig() (types.AnyMap, bool) {
+	logConfig := types.AnyMap(Config.Get("ratgo-log.main").ToAnyMap())
+	if logConfig.Get("Turn").ToString() != "on" {
+		return logConfig, false
+	}
+	if logConfig.Get("RootPath").ToString() == "" {
+		logConfig.Set("RootPath", Config.RuntimeLogPath)
+	}
+	return logConfig, true
+}
+
+// 执行用户挂载函数
+func runUserFunc() {
+	if len(UserFuncArray) > 0 {
+		for _, function := range UserFuncArray {
+			err := function()
+			if err != nil {
+				panic(err.Error())
+			}
+		}
+	}
+}
+
+// 执行服务启动函数
+func runOnStart() {
+	for _, function := range OnStartFuncArray {
+		if err := function(); err != nil {
+			panic(err.Error())
+		}
+	}
+}
+
+// 执行服务关闭函数并释放系统资源
+func runOnShutdown(tag string) {
+	for _, function := range OnShutdownFuncArray {
+		if err := function(); err != nil {
+			fmt.Printf("[%s]执行关闭函数失败 error: %v \r\n", tag, err)
+		}
+	}
+	closeResource(tag)
+}
+
+// 释放 mysql、redis连接池及日志文件句柄
+func closeResource(tag string) {
+	closers := []struct {
+		name  string
+		close func() error
+	}{
+		{"ext.GormV2", ext.GormV2.Close},
+		{"extend.Gorm", extend.Gorm.Close},
+		{"ext.Redis", ext.Redis.Close},
+		{"cache.Redis", cache.Redis.Close},
+		{"trace", trace.Shutdown},
+		{"config watcher", Config.StopWatch},
+		{"vdlog", vdlog.Close},
+	}
+	for _, closer := range closers {
+		if err := closer.close(); err != nil {
+			fmt.Printf("[%s]释放 %s 失败 error: %v \r\n", tag, closer.name, err)
+		}
+	}
 }
@@ -0,0 +1,220 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vdongchina/ratgo/ext"
+	"github.com/vdongchina/ratgo/utils/types"
+	"github.com/vdongchina/ratgo/utils/vdlog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// 配置热加载配置, 对应 ratgo 配置 reload 节点
+type ReloadOption struct {
+	Enable   bool
+	Interval int // 配置目录检查间隔(秒), 默认 5, 最小 1
+}
+
+// 配置变更回调, key 为订阅的配置路径, value 为变更后的值
+type ConfigSubscriber func(key string, value *types.AnyValue) error
+
+// 配置变更订阅者
+type configSubscriber struct {
+	key        string
+	subscriber ConfigSubscriber
+}
+
+// 运行期间 Set 的配置
+type runtimeSet struct {
+	key   string
+	value interface{}
+}
+
+// 订阅配置变更, key 为 . 拼接的配置路径(如 redis.master), 为空时订阅全部配置, ratgo 配置使用 ratgo.xxx
+func (cs *ConfigStorage) Subscribe(key string, subscriber ConfigSubscriber) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.subscribers = append(cs.subscribers, configSubscriber{key: key, subscriber: subscriber})
+}
+
+// 重新加载配置文件, 原子替换 DefinedConfig 后通知订阅者, 加载失败时保留原配置
+func (cs *ConfigStorage) Reload() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("reload config failed. error:%v", r))
+		}
+	}()
+
+	// 加载新配置
+	cs.lock.RLock()
+	next := &ConfigStorage{
+		DefinedConfig: types.AnyMap{},
+		sources:       map[string]ConfigSource{},
+		definedMap:    cs.definedMap,
+		runtimeSets:   append([]runtimeSet{}, cs.runtimeSets...),
+	}
+	cs.lock.RUnlock()
+	if err = next.loadLayers(filepath.Dir(cs.ConfigPath), cs.ConfigPath); err != nil {
+		return err
+	}
+	next.splitAppConfig(map[string]string{
+		"ConfigPath":     cs.ConfigPath,
+		"RuntimePath":    cs.RuntimePath,
+		"RuntimeLogPath": cs.RuntimeLogPath,
+	})
+
+	// 原子替换
+	cs.lock.Lock()
+	oldSnapshot := cs.snapshot()
+	cs.DefinedConfig = next.DefinedConfig
+	cs.appConfig = next.appConfig
+	cs.sources = next.sources
+	cs.secrets = next.secrets
+	newSnapshot := cs.snapshot()
+	subscribers := append([]configSubscriber{}, cs.subscribers...)
+	cs.lock.Unlock()
+
+	// 通知订阅者
+	for _, item := range subscribers {
+		oldValue, newValue := oldSnapshot.Get(item.key), newSnapshot.Get(item.key)
+		if item.key == "" {
+			oldValue, newValue = types.Eval(map[string]interface{}(oldSnapshot)), types.Eval(map[string]interface{}(newSnapshot))
+		}
+		if reflect.DeepEqual(oldValue.Value(), newValue.Value()) {
+			continue
+		}
+		if notifyErr := notifySubscriber(item, newValue); notifyErr != nil {
+			vdlog.StdLogger.Error(fmt.Sprintf("config '%s' subscriber failed. error:%v", item.key, notifyErr))
+		}
+	}
+	return nil
+}
+
+// 执行订阅者回调, 捕获 panic
+func notifySubscriber(item configSubscriber, value *types.AnyValue) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprint(r))
+		}
+	}()
+	return item.subscriber(item.key, value)
+}
+
+// 包含 ratgo 配置的完整配置, 用于比较变更
+func (cs *ConfigStorage) snapshot() types.AnyMap {
+	snapshot := types.AnyMap{}
+	for key, value := range cs.DefinedConfig {
+		snapshot[key] = value
+	}
+	if len(cs.appConfig) > 0 {
+		snapshot["ratgo"] = map[string]interface{}(cs.appConfig)
+	}
+	return snapshot
+}
+
+// 监听配置目录, 文件修改、新增、删除后重新加载配置, interval 小于等于0时不监听
+func (cs *ConfigStorage) Watch(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	cs.lock.Lock()
+	if cs.watchStop != nil {
+		cs.lock.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	cs.watchStop = stop
+	cs.lock.Unlock()
+
+	modTimes := cs.configModTimes()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				current := cs.configModTimes()
+				if reflect.DeepEqual(current, modTimes) {
+					continue
+				}
+				modTimes = current
+				if err := cs.Reload(); err != nil {
+					vdlog.StdLogger.Error(fmt.Sprintf("reload config failed, keep the previous config. error:%v", err))
+				} else {
+					vdlog.StdLogger.Info(fmt.Sprintf("config reloaded: %s", cs.ConfigPath))
+				}
+			}
+		}
+	}()
+}
+
+// 停止监听配置目录
+func (cs *ConfigStorage) StopWatch() error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if cs.watchStop != nil {
+		close(cs.watchStop)
+		cs.watchStop = nil
+	}
+	return nil
+}
+
+// 基础配置及运行模式配置文件的修改时间
+func (cs *ConfigStorage) configModTimes() map[string]time.Time {
+	modTimes := map[string]time.Time{}
+	for _, dirPath := range []string{filepath.Dir(cs.ConfigPath), cs.ConfigPath} {
+		dir, err := os.Open(dirPath)
+		if err != nil {
+			continue
+		}
+		files, _ := dir.Readdir(-1)
+		_ = dir.Close()
+		for _, file := range configFiles(files) {
+			modTimes[filepath.Join(dirPath, file.Name())] = file.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// 根据 ratgo 配置开启配置热加载, 并订阅数据库、redis、日志配置变更
+func initReload(tag string) {
+	option := ReloadOption{Interval: 5}
+	if loadOption(&option, Config.GetApp("reload").ToAnyMap()); !option.Enable || Config.definedMap != nil {
+		return
+	}
+	if option.Interval < 1 {
+		option.Interval = 1
+	}
+	if Config.InitDb == true {
+		Config.Subscribe("database", func(key string, value *types.AnyValue) error {
+			return ext.GormV2.Reload(value.ToAnyMap())
+		})
+	}
+	if Config.InitRedis == true {
+		Config.Subscribe("redis", func(key string, value *types.AnyValue) error {
+			return ext.Redis.Reload(value.ToAnyMap())
+		})
+	}
+	Config.Subscribe("ratgo-log.main", func(key string, value *types.AnyValue) error {
+		if logConfig, ok := loggerConfig(); ok {
+			_ = vdlog.Reload(map[string]interface{}(logConfig))
+		}
+		return nil
+	})
+	fmt.Printf("[%s]配置热加载, 检查间隔: %d 秒 \r\n", tag, option.Interval)
+	Config.Watch(time.Duration(option.Interval) * time.Second)
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 响应信封函数, 将 Result 包装为响应数据
+type EnvelopeFunc func(result *Result) interface{}
+
+// 响应信封
+var envelope EnvelopeFunc = DefaultEnvelope
+
+// 设置响应信封函数(Config.ResponseEnvelope 开启时生效)
+func SetEnvelope(fn EnvelopeFunc) {
+	envelope = fn
+}
+
+// 默认响应信封: {code,msg,data}
+func DefaultEnvelope(result *Result) interface{} {
+	return gin.H{
+		"code": result.Code,
+		"msg":  result.Msg,
+		"data": result.Data,
+	}
+}
+
+// Accept 协商支持的类型, 按优先顺序排列
+var negotiateOffers = []struct {
+	mime     string
+	respType string
+}{
+	{binding.MIMEJSON, RespJson},
+	{binding.MIMEXML, RespXml},
+	{binding.MIMEXML2, RespXml},
+	{binding.MIMEYAML, RespYaml},
+}
+
+// 根据 Accept 协商响应类型: 取 q 值最高的媒体类型, 其中明确包含 Json、Xml、Yaml 时按该顺序选择, 否则使用 Json
+// 浏览器 Accept(text/html 优先, application/xml;q=0.9)及 */* 均响应 Json
+func NegotiateType(context *gin.Context) string {
+	maxQ, preferred := 0.0, map[string]bool{}
+	for _, item := range strings.Split(context.GetHeader("Accept"), ",") {
+		mime, q := parseAccept(item)
+		if mime == "" || q <= 0 {
+			continue
+		}
+		if q > maxQ {
+			maxQ, preferred = q, map[string]bool{}
+		}
+		if q == maxQ {
+			preferred[mime] = true
+		}
+	}
+	for _, offer := range negotiateOffers {
+		if preferred[offer.mime] {
+			return offer.respType
+		}
+	}
+	return RespJson
+}
+
+// 解析 Accept 中的媒体类型及 q 值, q 值缺省为1
+func parseAccept(item string) (string, float64) {
+	params := strings.Split(item, ";")
+	mime, q := strings.ToLower(strings.TrimSpace(params[0])), 1.0
+	for _, param := range params[1:] {
+		if pair := strings.SplitN(strings.TrimSpace(param), "=", 2); len(pair) == 2 && strings.TrimSpace(pair[0]) == "q" {
+			value, err := strconv.ParseFloat(strings.TrimSpace(pair[1]), 64)
+			if err != nil {
+				return "", 0
+			}
+			q = value
+		}
+	}
+	return mime, q
+}
+
+// 响应数据, 开启信封时结构化类型(Json、Xml、Jsonp、Yaml)使用信封包装
+func responseData(result *Result) interface{} {
+	if Config.ResponseEnvelope && envelope != nil {
+		return envelope(result)
+	}
+	return result.Data
+}
+
+// 渲染 Result, 未知响应类型使用 Json
+func Render(context *gin.Context, result *Result) {
+	respType := result.Type
+	if respType == "" {
+		respType = NegotiateType(context)
+	}
+	switch respType {
+	case RespString:
+		context.String(result.Status, result.Msg)
+	case RespJson:
+		data := responseData(result)
+		context.Set("response", data)
+		context.JSON(result.Status, data)
+	case RespHtml:
+		context.HTML(result.Status, result.Msg, result.Data)
+	case RespXml:
+		data := responseData(result)
+		context.Set("response", data)
+		context.XML(result.Status, xmlData(data))
+	case RespJsonp:
+		data := responseData(result)
+		context.Set("response", data)
+		context.JSONP(result.Status, data)
+	case RespYaml:
+		data := responseData(result)
+		context.Set("response", data)
+		context.YAML(result.Status, data)
+	case RespProtoBuf:
+		if _, ok := result.Data.(proto.Message); !ok {
+			context.String(http.StatusInternalServerError, "protobuf data must be proto.Message")
+			return
+		}
+		context.ProtoBuf(result.Status, result.Data)
+	case RespFile:
+		filePath := fmt.Sprintf("%v", result.Data)
+		if result.Msg != "" {
+			context.FileAttachment(filePath, result.Msg)
+		} else {
+			context.File(filePath)
+		}
+	case RespStream:
+		switch data := result.Data.(type) {
+		case func(w io.Writer) bool:
+			context.Status(result.Status)
+			context.Stream(data)
+		case io.Reader:
+			contentType := result.Msg
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			context.DataFromReader(result.Status, -1, contentType, data, nil)
+		default:
+			context.String(http.StatusInternalServerError, "stream data must be io.Reader or func(w io.Writer) bool")
+		}
+	default: // 未知类型使用 Json
+		data := responseData(result)
+		context.Set("response", data)
+		context.JSON(result.Status, data)
+	}
+}
+
+// xml map, 以 key 作为元素名称
+type xmlMap map[string]interface{}
+
+// 实现 xml.Marshaler
+func (m xmlMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if start.Name.Local == "" || start.Name.Local == "xmlMap" { // 根元素
+		start.Name.Local = "response"
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := e.EncodeElement(m[key], xml.StartElement{Name: xml.Name{Local: key}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// encoding/xml 不支持 map, 递归将 string 为 key 的 map 转换为 xmlMap
+func xmlData(data interface{}) interface{} {
+	value := reflect.ValueOf(data)
+	if !value.IsValid() || value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String {
+		return data
+	}
+	m := xmlMap{}
+	iter := value.MapRange()
+	for iter.Next() {
+		m[iter.Key().String()] = xmlData(iter.Value().Interface())
+	}
+	return m
+}
@@ -0,0 +1,44 @@
+package ratgo
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateType_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := map[string]string{
+		"":    RespJson,
+		"*/*": RespJson,
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8":                       RespJson,
+		"text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8": RespJson,
+		"application/json, text/plain, */*":                                                     RespJson,
+		"application/xml":                                                                       RespXml,
+		"text/xml;q=0.8, application/json;q=0.5":                                                RespXml,
+		"application/yaml, application/xml":                                                     RespXml,
+		"application/x-yaml":                                                                    RespYaml,
+		"application/json;q=0, application/x-yaml;q=0.1":                                        RespYaml,
+		"application/xml;q=abc, application/json;q=0.1":                                         RespJson,
+		"application/xml; charset=utf-8":                                                        RespXml,
+	}
+	for accept, expect := range cases {
+		context, _ := gin.CreateTestContext(httptest.NewRecorder())
+		context.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		context.Request.Header.Set("Accept", accept)
+		if respType := NegotiateType(context); respType != expect {
+			t.Errorf("'%s' expect %s, but receive %s", accept, expect, respType)
+		}
+	}
+
+	// 浏览器请求默认 Result 响应 Json
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	context.Request.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	Render(context, NewResult().SetData(gin.H{"id": 1}))
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json; charset=utf-8" || recorder.Body.String() != `{"id":1}` {
+		t.Fatal(contentType, recorder.Body.String())
+	}
+}
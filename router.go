@@ -11,21 +11,50 @@ package ratgo
 
 import (
 	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
 	"strings"
 )
 
 // 简易模式路由容器
 type GeneralMap map[string]ControllerInterface
 
+// 简易模式默认允许的请求方法, OPTIONS 请求自动响应 Allow 头
+var DefaultAllowMethods = []string{http.MethodHead, http.MethodGet, http.MethodPost}
+
+// 简易模式注册的全部请求方法
+var generalMethods = []string{
+	http.MethodHead, http.MethodGet, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// Restful路由
+type RestfulRoute struct {
+	Method     string              // 请求方法
+	Path       string              // 完整路径, 支持 :name、*name 参数
+	Group      string              // 分组名称, 对应 MiddleWare.SetGroup 的 groupName
+	Controller ControllerInterface // 控制器模板
+	MiddleWare []gin.HandlerFunc   // 路由中间件
+}
+
+// Restful路由分组
+type RestfulGroup struct {
+	prefix     string
+	middleWare []gin.HandlerFunc
+	storage    *RouterStorage
+}
+
 // 路由存储器
 type RouterStorage struct {
-	Mode          string
+	Mode          string // 最后注册的路由模式, 简易路由与 Restful 路由可同时使用
 	generalMap    GeneralMap
 	generalPath   map[string][]string
+	allowMethods  map[string][]string
 	staticMap     map[string]string
 	staticFileMap map[string]string
 	htmlGlob      []string
 	html          []string
+	restful       []*RestfulRoute
 }
 
 // router对象
@@ -36,9 +65,11 @@ func init() {
 		Mode:          "General",
 		generalMap:    GeneralMap{},
 		generalPath:   map[string][]string{},
+		allowMethods:  map[string][]string{},
 		staticMap:     map[string]string{},
 		staticFileMap: map[string]string{},
 		htmlGlob:      make([]string, 0),
+		restful:       make([]*RestfulRoute, 0),
 	}
 }
 
@@ -85,6 +116,52 @@ func (rs *RouterStorage) GetGeneral(path string) ControllerInterface {
 	return nil
 }
 
+// 简易模式 - 设置路由允许的请求方法, 优先于控制器的 AllowMethods(), path 如 api/v1/user/info
+func (rs *RouterStorage) SetAllowMethods(path string, methods ...string) {
+	rs.allowMethods[joinPath("", path)] = normalizeMethods(methods)
+}
+
+// 简易模式 - 获取路由允许的请求方法, 依次读取 SetAllowMethods、控制器 AllowMethods()、DefaultAllowMethods
+func (rs *RouterStorage) GetAllowMethods(path string) []string {
+	path = joinPath("", path)
+	if methods, ok := rs.allowMethods[path]; ok {
+		return methods
+	}
+	if controller, ok := rs.generalMap[path].(AllowMethodsInterface); ok {
+		return normalizeMethods(controller.AllowMethods())
+	}
+	return normalizeMethods(DefaultAllowMethods)
+}
+
+// 请求方法格式化: 转大写、去重, 允许 GET 时同时允许 HEAD
+func normalizeMethods(methods []string) []string {
+	result := make([]string, 0, len(methods)+1)
+	exists := map[string]bool{}
+	for _, method := range methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "" || exists[method] {
+			continue
+		}
+		if method == http.MethodGet && !exists[http.MethodHead] {
+			exists[http.MethodHead] = true
+			result = append(result, http.MethodHead)
+		}
+		exists[method] = true
+		result = append(result, method)
+	}
+	return result
+}
+
+// 是否允许请求方法
+func methodAllowed(methods []string, method string) bool {
+	for _, value := range methods {
+		if value == method {
+			return true
+		}
+	}
+	return false
+}
+
 // 简易模式 - 设置路径
 func (rs *RouterStorage) SetGeneralPath(group string, pathSlice []string) {
 	// 拼接路由规则字符串
@@ -162,3 +239,119 @@ func (rs *RouterStorage) SetHTMLGlob(pattern string) {
 func (rs *RouterStorage) GetHTMLGlob() []string {
 	return rs.htmlGlob
 }
+
+// Restful - 注册路由
+func (rs *RouterStorage) Restful(method string, path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	rs.root().Handle(method, path, controller, handlerFunc...)
+}
+
+// Restful - GET
+func (rs *RouterStorage) GET(path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	rs.Restful(http.MethodGet, path, controller, handlerFunc...)
+}
+
+// Restful - POST
+func (rs *RouterStorage) POST(path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	rs.Restful(http.MethodPost, path, controller, handlerFunc...)
+}
+
+// Restful - PUT
+func (rs *RouterStorage) PUT(path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	rs.Restful(http.MethodPut, path, controller, handlerFunc...)
+}
+
+// Restful - PATCH
+func (rs *RouterStorage) PATCH(path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	rs.Restful(http.MethodPatch, path, controller, handlerFunc...)
+}
+
+// Restful - DELETE
+func (rs *RouterStorage) DELETE(path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	rs.Restful(http.MethodDelete, path, controller, handlerFunc...)
+}
+
+// Restful - 路由分组, prefix同时作为分组中间件名称
+func (rs *RouterStorage) Group(prefix string, fn func(group *RestfulGroup), handlerFunc ...gin.HandlerFunc) {
+	rs.root().Group(prefix, fn, handlerFunc...)
+}
+
+// Restful - 获取路由列表
+func (rs *RouterStorage) GetRestful() []*RestfulRoute {
+	return rs.restful
+}
+
+// Restful - 根分组
+func (rs *RouterStorage) root() *RestfulGroup {
+	return &RestfulGroup{
+		prefix:     "",
+		middleWare: make([]gin.HandlerFunc, 0),
+		storage:    rs,
+	}
+}
+
+// 注册路由
+func (rg *RestfulGroup) Handle(method string, path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	// 修改路由模式
+	if rg.storage.Mode != "Restful" {
+		rg.storage.Mode = "Restful"
+	}
+	middleWare := make([]gin.HandlerFunc, 0, len(rg.middleWare)+len(handlerFunc))
+	middleWare = append(middleWare, rg.middleWare...)
+	middleWare = append(middleWare, handlerFunc...)
+	rg.storage.restful = append(rg.storage.restful, &RestfulRoute{
+		Method:     strings.ToUpper(method),
+		Path:       joinPath(rg.prefix, path),
+		Group:      rg.prefix,
+		Controller: controller,
+		MiddleWare: middleWare,
+	})
+}
+
+// GET
+func (rg *RestfulGroup) GET(path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	rg.Handle(http.MethodGet, path, controller, handlerFunc...)
+}
+
+// POST
+func (rg *RestfulGroup) POST(path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	rg.Handle(http.MethodPost, path, controller, handlerFunc...)
+}
+
+// PUT
+func (rg *RestfulGroup) PUT(path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	rg.Handle(http.MethodPut, path, controller, handlerFunc...)
+}
+
+// PATCH
+func (rg *RestfulGroup) PATCH(path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	rg.Handle(http.MethodPatch, path, controller, handlerFunc...)
+}
+
+// DELETE
+func (rg *RestfulGroup) DELETE(path string, controller ControllerInterface, handlerFunc ...gin.HandlerFunc) {
+	rg.Handle(http.MethodDelete, path, controller, handlerFunc...)
+}
+
+// 子分组
+func (rg *RestfulGroup) Group(prefix string, fn func(group *RestfulGroup), handlerFunc ...gin.HandlerFunc) {
+	middleWare := make([]gin.HandlerFunc, 0, len(rg.middleWare)+len(handlerFunc))
+	middleWare = append(middleWare, rg.middleWare...)
+	middleWare = append(middleWare, handlerFunc...)
+	fn(&RestfulGroup{
+		prefix:     strings.Trim(joinPath(rg.prefix, prefix), "/"),
+		middleWare: middleWare,
+		storage:    rg.storage,
+	})
+}
+
+// 拼接路径
+func joinPath(prefix string, path string) string {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if prefix == "" {
+		return "/" + path
+	} else if path == "" {
+		return "/" + prefix
+	}
+	return fmt.Sprintf("/%s/%s", prefix, path)
+}
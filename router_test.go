@@ -0,0 +1,180 @@
+package ratgo
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 替换全局路由及中间件容器, 返回 WebServer 及恢复函数
+func testWebServer() (*WebServer, func()) {
+	gin.SetMode(gin.TestMode)
+	router, middleWare := Router, MiddleWare
+	Router = &RouterStorage{
+		Mode:          "General",
+		generalMap:    GeneralMap{},
+		generalPath:   map[string][]string{},
+		allowMethods:  map[string][]string{},
+		staticMap:     map[string]string{},
+		staticFileMap: map[string]string{},
+		htmlGlob:      make([]string, 0),
+		restful:       make([]*RestfulRoute, 0),
+	}
+	MiddleWare = &MiddleWareStorage{
+		global: make(middleWareList, 0),
+		group:  map[string]middleWareList{},
+		route:  map[string]middleWareList{},
+	}
+	return &WebServer{gin: gin.New()}, func() {
+		Router, MiddleWare = router, middleWare
+	}
+}
+
+// 发送请求
+func testServe(handler http.Handler, method string, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// 响应路由规则及路径参数
+type echoController struct {
+	Controller
+}
+
+func (ec *echoController) Exec() {
+	ec.Result().SetType(RespString).SetMsg(ec.Context().FullPath() + " id=" + ec.Param("id") + " param_1=" + ec.Param("param_1"))
+}
+
+func TestJoinPath_01(t *testing.T) {
+	cases := map[[2]string]string{
+		{"", ""}:              "/",
+		{"", "users"}:         "/users",
+		{"/api/", "/users/"}:  "/api/users",
+		{"api", ""}:           "/api",
+		{" api/v1 ", ":id"}:   "/api/v1/:id",
+		{"api", "users/*all"}: "/api/users/*all",
+	}
+	for args, expect := range cases {
+		if path := joinPath(args[0], args[1]); path != expect {
+			t.Errorf("%v expect '%s', but receive '%s'", args, expect, path)
+		}
+	}
+}
+
+func TestRestfulRouter_01(t *testing.T) {
+	ws, restore := testWebServer()
+	defer restore()
+	Router.GET("/users/:id", &echoController{})
+	Router.Group("/api/", func(group *RestfulGroup) {
+		group.POST("users", &echoController{})
+		group.Group("v1", func(group *RestfulGroup) {
+			group.GET("/users/:id/", &echoController{})
+			group.DELETE("files/*id", &echoController{})
+		})
+	})
+	Router.General("admin", GeneralMap{"user/info": &echoController{}})
+	if err := ws.registerRouter(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 分组前缀拼接及分组名称
+	groups := map[string]string{}
+	for _, route := range Router.GetRestful() {
+		groups[route.Method+" "+route.Path] = route.Group
+	}
+	expectGroups := map[string]string{
+		"GET /users/:id":           "",
+		"POST /api/users":          "api",
+		"GET /api/v1/users/:id":    "api/v1",
+		"DELETE /api/v1/files/*id": "api/v1",
+	}
+	for key, group := range expectGroups {
+		if value, ok := groups[key]; !ok || value != group {
+			t.Errorf("route '%s' expect group '%s', but receive '%s' %v", key, group, value, ok)
+		}
+	}
+
+	// 路径参数, 简易路由与 Restful 路由共存
+	cases := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/users/7", "/users/:id id=7 param_1="},
+		{http.MethodPost, "/api/users", "/api/users id= param_1="},
+		{http.MethodGet, "/api/v1/users/8", "/api/v1/users/:id id=8 param_1="},
+		{http.MethodDelete, "/api/v1/files/a/b.txt", "/api/v1/files/*id id=/a/b.txt param_1="},
+		{http.MethodGet, "/admin/user/info", "/admin/:param_1/:param_2 id= param_1=user"},
+	}
+	for _, c := range cases {
+		if recorder := testServe(ws.gin, c.method, c.path, nil); recorder.Code != http.StatusOK || recorder.Body.String() != c.body {
+			t.Errorf("%s %s receive %d '%s'", c.method, c.path, recorder.Code, recorder.Body.String())
+		}
+	}
+	if code := testServe(ws.gin, http.MethodGet, "/api/users", nil).Code; code != http.StatusNotFound && code != http.StatusMethodNotAllowed {
+		t.Fatal(code)
+	}
+}
+
+// 声明允许请求方法的控制器
+type putController struct {
+	echoController
+}
+
+func (pc *putController) AllowMethods() []string {
+	return []string{"put"}
+}
+
+func TestGeneralAllowMethods_01(t *testing.T) {
+	ws, restore := testWebServer()
+	defer restore()
+	Router.General("admin", GeneralMap{
+		"user/info": &echoController{},
+		"user/save": &echoController{},
+		"user/edit": &putController{},
+	})
+	Router.SetAllowMethods("/admin/user/save/", "post", "DELETE")
+	_ = ws.registerRouter()
+
+	preflight := map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": http.MethodPost}
+	cases := []struct {
+		method       string
+		path         string
+		header       map[string]string
+		status       int
+		allow        string
+		allowMethods string
+	}{
+		// 默认 HEAD、GET、POST
+		{http.MethodGet, "/admin/user/info", nil, http.StatusOK, "", ""},
+		{http.MethodHead, "/admin/user/info", nil, http.StatusOK, "", ""},
+		{http.MethodPost, "/admin/user/info", nil, http.StatusOK, "", ""},
+		{http.MethodPut, "/admin/user/info", nil, http.StatusMethodNotAllowed, "HEAD, GET, POST, OPTIONS", ""},
+		{http.MethodDelete, "/admin/user/info", nil, http.StatusMethodNotAllowed, "HEAD, GET, POST, OPTIONS", ""},
+		{http.MethodOptions, "/admin/user/info", nil, http.StatusNoContent, "HEAD, GET, POST, OPTIONS", ""},
+		{http.MethodOptions, "/admin/user/info", preflight, http.StatusNoContent, "HEAD, GET, POST, OPTIONS", "HEAD, GET, POST, OPTIONS"},
+
+		// SetAllowMethods 优先
+		{http.MethodPost, "/admin/user/save", nil, http.StatusOK, "", ""},
+		{http.MethodDelete, "/admin/user/save", nil, http.StatusOK, "", ""},
+		{http.MethodGet, "/admin/user/save", nil, http.StatusMethodNotAllowed, "POST, DELETE, OPTIONS", ""},
+		{http.MethodOptions, "/admin/user/save", preflight, http.StatusNoContent, "POST, DELETE, OPTIONS", "POST, DELETE, OPTIONS"},
+
+		// 控制器 AllowMethods()
+		{http.MethodPut, "/admin/user/edit", nil, http.StatusOK, "", ""},
+		{http.MethodPost, "/admin/user/edit", nil, http.StatusMethodNotAllowed, "PUT, OPTIONS", ""},
+	}
+	for _, c := range cases {
+		recorder := testServe(ws.gin, c.method, c.path, c.header)
+		header := recorder.Header()
+		if recorder.Code != c.status || header.Get("Allow") != c.allow || header.Get("Access-Control-Allow-Methods") != c.allowMethods {
+			t.Errorf("%s %s %v receive %d Allow '%s' Access-Control-Allow-Methods '%s'", c.method, c.path, c.header, recorder.Code, header.Get("Allow"), header.Get("Access-Control-Allow-Methods"))
+		}
+	}
+}
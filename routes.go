@@ -0,0 +1,212 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// 路由信息
+type RouteInfo struct {
+	Mode       string   `json:"mode"`       // 路由类型: General、Restful、Static、StaticFile、HTMLGlob
+	Path       string   `json:"path"`       // 访问路径
+	Pattern    string   `json:"pattern"`    // gin注册规则
+	Methods    []string `json:"methods"`    // 请求方法
+	Controller string   `json:"controller"` // 控制器类型
+	Group      string   `json:"group"`      // 分组名称
+	MiddleWare []string `json:"middleWare"` // 分组及路由中间件
+	Root       string   `json:"root"`       // 静态文件根路径
+}
+
+// 获取全部路由信息(不含全局中间件, 可通过 MiddleWareNames(MiddleWare.GetGlobal()) 获取)
+func (rs *RouterStorage) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0)
+
+	// 简易路由
+	for path, controller := range rs.generalMap {
+		group := rs.generalGroup(path)
+		segments := strings.Split(strings.Trim(strings.TrimPrefix(path, "/"+group), "/"), "/")
+		patternSlice := []string{group}
+		for i := 1; i <= len(segments); i++ {
+			patternSlice = append(patternSlice, fmt.Sprintf(":param_%d", i))
+		}
+		middleWare := MiddleWare.GetGroupChain(group)
+		middleWare = append(middleWare, MiddleWare.GetRoute(path)...)
+		routes = append(routes, RouteInfo{
+			Mode:       "General",
+			Path:       path,
+			Pattern:    "/" + strings.Join(patternSlice, "/"),
+			Methods:    rs.GetAllowMethods(path),
+			Controller: controllerName(controller),
+			Group:      group,
+			MiddleWare: MiddleWareNames(middleWare),
+		})
+	}
+
+	// Restful路由
+	for _, route := range rs.restful {
+		middleWare := MiddleWare.GetGroupChain(route.Group)
+		middleWare = append(middleWare, route.MiddleWare...)
+		middleWare = append(middleWare, MiddleWare.GetRoute(route.Path)...)
+		routes = append(routes, RouteInfo{
+			Mode:       "Restful",
+			Path:       route.Path,
+			Pattern:    route.Path,
+			Methods:    []string{route.Method},
+			Controller: controllerName(route.Controller),
+			Group:      route.Group,
+			MiddleWare: MiddleWareNames(middleWare),
+		})
+	}
+
+	// 静态文件
+	for path, root := range rs.staticMap {
+		routes = append(routes, RouteInfo{Mode: "Static", Path: path, Pattern: joinPath(path, "*filepath"), Methods: []string{http.MethodGet, http.MethodHead}, MiddleWare: []string{}, Root: root})
+	}
+	for path, root := range rs.staticFileMap {
+		routes = append(routes, RouteInfo{Mode: "StaticFile", Path: path, Pattern: path, Methods: []string{http.MethodGet, http.MethodHead}, MiddleWare: []string{}, Root: root})
+	}
+	for _, pattern := range rs.htmlGlob {
+		routes = append(routes, RouteInfo{Mode: "HTMLGlob", Methods: []string{}, MiddleWare: []string{}, Root: pattern})
+	}
+
+	// 排序
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Mode != routes[j].Mode {
+			return routes[i].Mode < routes[j].Mode
+		}
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return strings.Join(routes[i].Methods, ",") < strings.Join(routes[j].Methods, ",")
+	})
+	return routes
+}
+
+// 简易路由所属分组
+func (rs *RouterStorage) generalGroup(path string) string {
+	group := ""
+	for key := range rs.generalPath {
+		if strings.HasPrefix(path, "/"+key+"/") && len(key) > len(group) {
+			group = key
+		}
+	}
+	return group
+}
+
+// 控制器类型名称
+func controllerName(controller interface{}) string {
+	if controller == nil {
+		return ""
+	}
+	return reflect.TypeOf(controller).String()
+}
+
+// 中间件函数名称
+func MiddleWareNames(handlerFuncSlice []gin.HandlerFunc) []string {
+	names := make([]string, 0, len(handlerFuncSlice))
+	for _, handlerFunc := range handlerFuncSlice {
+		names = append(names, runtime.FuncForPC(reflect.ValueOf(handlerFunc).Pointer()).Name())
+	}
+	return names
+}
+
+// 路由表数据
+func routeTable() map[string]interface{} {
+	return map[string]interface{}{
+		"mode":       Router.Mode,
+		"middleWare": MiddleWareNames(MiddleWare.GetGlobal()),
+		"routes":     Router.Routes(),
+	}
+}
+
+/**************************************** 管理接口 ****************************************/
+// 注册路由表管理接口, 配置项 AdminPath 为空时不注册
+// 请求需携带 X-Admin-Token 头(与配置项 AdminToken 一致), 未配置 AdminToken 时仅允许本机访问
+func (ws *WebServer) registerAdmin() error {
+	if Config.AdminPath == "" {
+		return nil
+	}
+	ws.gin.GET(joinPath(Config.AdminPath, "routes"), adminAuth, func(context *gin.Context) {
+		context.JSON(http.StatusOK, routeTable())
+	})
+	return nil
+}
+
+// 管理接口鉴权, 本机访问以连接地址判断, 不信任 X-Forwarded-For 等请求头
+func adminAuth(context *gin.Context) {
+	if Config.AdminToken != "" {
+		if subtle.ConstantTimeCompare([]byte(context.GetHeader("X-Admin-Token")), []byte(Config.AdminToken)) != 1 {
+			context.AbortWithStatus(http.StatusUnauthorized)
+		}
+		return
+	}
+	if ip := net.ParseIP(context.RemoteIP()); ip == nil || !ip.IsLoopback() {
+		context.AbortWithStatus(http.StatusForbidden)
+	}
+}
+
+/**************************************** 内置命令 ****************************************/
+// 路由表命令
+type routesCommand struct {
+	Command
+	json bool
+}
+
+// 命令说明
+func (rc *routesCommand) Usage() string {
+	return "Show registered routes, controllers and middleware"
+}
+
+// 注册命令参数
+func (rc *routesCommand) Flags(flagSet *flag.FlagSet) {
+	flagSet.BoolVar(&rc.json, "json", false, "output as json")
+}
+
+// 执行方法
+func (rc *routesCommand) Exec() error {
+	var output io.Writer = os.Stdout
+	if cmdServer := GetCmdServer(); cmdServer != nil {
+		output = cmdServer.output
+	}
+	if rc.json {
+		data, err := json.MarshalIndent(routeTable(), "", "  ")
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(output, string(data))
+		return nil
+	}
+	writer := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(writer, "MODE\tMETHODS\tPATH\tCONTROLLER/ROOT\tMIDDLEWARE\n")
+	for _, route := range Router.Routes() {
+		target := route.Controller
+		if target == "" {
+			target = route.Root
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", route.Mode, strings.Join(route.Methods, ","), route.Path, target, strings.Join(route.MiddleWare, ","))
+	}
+	_ = writer.Flush()
+	_, _ = fmt.Fprintf(output, "global middleware: %s\n", strings.Join(MiddleWareNames(MiddleWare.GetGlobal()), ","))
+	return nil
+}
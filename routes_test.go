@@ -0,0 +1,64 @@
+package ratgo
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuth_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/_admin/routes", adminAuth, func(context *gin.Context) {
+		context.String(http.StatusOK, "ok")
+	})
+	request := func(remoteAddr string, header map[string]string) int {
+		req := httptest.NewRequest(http.MethodGet, "/_admin/routes", nil)
+		req.RemoteAddr = remoteAddr
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+	defer func(token string) { Config.AdminToken = token }(Config.AdminToken)
+
+	// 未配置令牌时仅允许本机连接, 伪造 X-Forwarded-For 无效
+	Config.AdminToken = ""
+	cases := []struct {
+		remoteAddr string
+		header     map[string]string
+		expect     int
+	}{
+		{"127.0.0.1:5678", nil, http.StatusOK},
+		{"[::1]:5678", nil, http.StatusOK},
+		{"203.0.113.9:5678", nil, http.StatusForbidden},
+		{"203.0.113.9:5678", map[string]string{"X-Forwarded-For": "127.0.0.1"}, http.StatusForbidden},
+		{"203.0.113.9:5678", map[string]string{"X-Real-Ip": "127.0.0.1"}, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		if code := request(c.remoteAddr, c.header); code != c.expect {
+			t.Errorf("%s %v expect %d, but receive %d", c.remoteAddr, c.header, c.expect, code)
+		}
+	}
+
+	// 配置令牌后须携带一致的令牌, 本机亦不例外
+	Config.AdminToken = "secret"
+	cases = []struct {
+		remoteAddr string
+		header     map[string]string
+		expect     int
+	}{
+		{"203.0.113.9:5678", map[string]string{"X-Admin-Token": "secret"}, http.StatusOK},
+		{"203.0.113.9:5678", map[string]string{"X-Admin-Token": "secreT"}, http.StatusUnauthorized},
+		{"203.0.113.9:5678", map[string]string{"X-Admin-Token": "secret1"}, http.StatusUnauthorized},
+		{"127.0.0.1:5678", nil, http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		if code := request(c.remoteAddr, c.header); code != c.expect {
+			t.Errorf("%s %v expect %d, but receive %d", c.remoteAddr, c.header, c.expect, code)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/vdongchina/ratgo/utils/encrypt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 配置加密密钥的环境变量, 值为 base64 编码的 16、24 或 32 字节密钥(AES-128/192/256-GCM)
+const ConfigSecretKeyEnv = "RATGO_CONFIG_KEY"
+
+// 加密配置值前缀, 如 Password: enc:xxxx
+const configEncryptedPrefix = "enc:"
+
+// 密文配置打印时的掩码
+const configSecretMask = "******"
+
+// 配置占位符: ${env:NAME} 读取环境变量, ${file:/run/secrets/x} 读取文件内容(去除末尾换行)
+var configSecretPattern = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// 加密配置值, 返回 enc: 前缀的密文, 密钥读取自环境变量 RATGO_CONFIG_KEY
+func EncryptConfigValue(plaintext string) (string, error) {
+	key, err := configSecretKey()
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := encrypt.AesGcmEncrypt([]byte(plaintext), key)
+	if err != nil {
+		return "", err
+	}
+	return configEncryptedPrefix + ciphertext, nil
+}
+
+// 解密 enc: 前缀的配置值
+func DecryptConfigValue(value string) (string, error) {
+	key, err := configSecretKey()
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := encrypt.AesGcmDecrypt(strings.TrimPrefix(value, configEncryptedPrefix), key)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("decrypt failed. error:%v", err))
+	}
+	return string(plaintext), nil
+}
+
+// 读取配置加密密钥
+func configSecretKey() ([]byte, error) {
+	encoded := strings.TrimSpace(os.Getenv(ConfigSecretKeyEnv))
+	if encoded == "" {
+		return nil, errors.New(fmt.Sprintf("environment variable '%s' is not set", ConfigSecretKeyEnv))
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("environment variable '%s' must be base64 encoded. error:%v", ConfigSecretKeyEnv, err))
+	}
+	return key, nil
+}
+
+// 解析全部配置中的占位符及加密值, 记录密文配置路径, 失败时列出全部错误
+func (cs *ConfigStorage) resolveSecrets() {
+	cs.secrets = map[string]bool{}
+	errs := make([]string, 0)
+	for key, value := range cs.DefinedConfig {
+		cs.DefinedConfig[key] = cs.resolveValue(key, value, &errs)
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		panic(strings.Join(errs, "; "))
+	}
+}
+
+// 递归解析配置值
+func (cs *ConfigStorage) resolveValue(path string, value interface{}, errs *[]string) interface{} {
+	switch value.(type) {
+	case string:
+		resolved, ok, err := resolveSecret(value.(string))
+		if err != nil {
+			*errs = append(*errs, fmt.Sprintf("resolve config '%s' failed. %v", path, err))
+			return value
+		}
+		if ok {
+			cs.secrets[path] = true
+		}
+		return resolved
+	case map[string]interface{}:
+		for k, v := range value.(map[string]interface{}) {
+			value.(map[string]interface{})[k] = cs.resolveValue(path+"."+k, v, errs)
+		}
+	case []interface{}:
+		slice := make([]interface{}, len(value.([]interface{})))
+		for i, v := range value.([]interface{}) {
+			slice[i] = cs.resolveValue(path+"."+strconv.Itoa(i), v, errs)
+		}
+		return slice
+	case []string:
+		slice := make([]string, len(value.([]string)))
+		for i, v := range value.([]string) {
+			slice[i] = cs.resolveValue(path+"."+strconv.Itoa(i), v, errs).(string)
+		}
+		return slice
+	}
+	return value
+}
+
+// 解析字符串中的占位符及加密值, 返回解析后的值及是否为密文
+func resolveSecret(value string) (string, bool, error) {
+	if strings.HasPrefix(value, configEncryptedPrefix) {
+		plaintext, err := DecryptConfigValue(value)
+		return plaintext, true, err
+	}
+	if !configSecretPattern.MatchString(value) {
+		return value, false, nil
+	}
+	var resolveErr error
+	resolved := configSecretPattern.ReplaceAllStringFunc(value, func(match string) string {
+		group := configSecretPattern.FindStringSubmatch(match)
+		switch group[1] {
+		case "env":
+			if v, ok := os.LookupEnv(group[2]); ok {
+				return v
+			}
+			resolveErr = errors.New(fmt.Sprintf("environment variable '%s' is not set", group[2]))
+		case "file":
+			buf, err := ioutil.ReadFile(group[2])
+			if err == nil {
+				return strings.TrimRight(string(buf), "\r\n")
+			}
+			resolveErr = errors.New(fmt.Sprintf("read secret file '%s' failed. error:%v", group[2], err))
+		}
+		return match
+	})
+	return resolved, true, resolveErr
+}
+
+// 读取配置副本, 由占位符、加密值解析的配置替换为掩码, 用于打印配置; ratgo 配置使用 ratgo.xxx, key 为空时返回全部配置
+func (cs *ConfigStorage) Masked(key string) interface{} {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	var value interface{}
+	switch {
+	case key == "":
+		value = map[string]interface{}(cs.snapshot())
+	case key == "ratgo":
+		value = map[string]interface{}(cs.appConfig)
+	case strings.HasPrefix(key, "ratgo."):
+		value = cs.appConfig.Get(strings.TrimPrefix(key, "ratgo.")).Value()
+	default:
+		value = cs.DefinedConfig.Get(key).Value()
+	}
+	return cs.mask(key, value)
+}
+
+// 递归复制配置值并替换密文
+func (cs *ConfigStorage) mask(path string, value interface{}) interface{} {
+	if cs.secrets[path] {
+		return configSecretMask
+	}
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	switch value.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(value.(map[string]interface{})))
+		for k, v := range value.(map[string]interface{}) {
+			masked[k] = cs.mask(join(k), v)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(value.([]interface{})))
+		for i, v := range value.([]interface{}) {
+			masked[i] = cs.mask(join(strconv.Itoa(i)), v)
+		}
+		return masked
+	case []string:
+		masked := make([]string, len(value.([]string)))
+		for i, v := range value.([]string) {
+			masked[i] = cs.mask(join(strconv.Itoa(i)), v).(string)
+		}
+		return masked
+	}
+	return value
+}
+
+/**************************************** 加密命令 ****************************************/
+// 内置命令: 加密配置值, 输出 enc: 前缀的密文
+type configEncryptCommand struct {
+	Command
+	genKey bool
+}
+
+// 命令说明
+func (cc *configEncryptCommand) Usage() string {
+	return "Encrypt config values with " + ConfigSecretKeyEnv + ", usage: config:encrypt [-genkey] value..."
+}
+
+// 注册命令参数
+func (cc *configEncryptCommand) Flags(flagSet *flag.FlagSet) {
+	flagSet.BoolVar(&cc.genKey, "genkey", false, "generate a random AES-256 key for "+ConfigSecretKeyEnv)
+}
+
+// 执行方法
+func (cc *configEncryptCommand) Exec() error {
+	var output io.Writer = os.Stdout
+	if cmdServer := GetCmdServer(); cmdServer != nil {
+		output = cmdServer.output
+	}
+	if cc.genKey {
+		key := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(output, base64.StdEncoding.EncodeToString(key))
+		return nil
+	}
+	if len(cc.Args()) == 0 {
+		return errors.New("missing value to encrypt")
+	}
+	for _, value := range cc.Args() {
+		ciphertext, err := EncryptConfigValue(value)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(output, ciphertext)
+	}
+	return nil
+}
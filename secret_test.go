@@ -0,0 +1,95 @@
+package ratgo
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigSecret_01(t *testing.T) {
+	_ = os.Setenv(ConfigSecretKeyEnv, base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")))
+	defer os.Unsetenv(ConfigSecretKeyEnv)
+	ciphertext, err := EncryptConfigValue("p@ss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := DecryptConfigValue(ciphertext); err != nil || plaintext != "p@ss" {
+		t.Fatal(plaintext, err)
+	}
+
+	// 篡改密文及错误密钥
+	if _, err := DecryptConfigValue(ciphertext[:len(ciphertext)-4] + "AAAA"); err == nil {
+		t.FailNow()
+	}
+	_ = os.Setenv(ConfigSecretKeyEnv, base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210")))
+	if _, err := DecryptConfigValue(ciphertext); err == nil {
+		t.FailNow()
+	}
+	_ = os.Unsetenv(ConfigSecretKeyEnv)
+	if _, err := EncryptConfigValue("p@ss"); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestResolveSecret_01(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ratgo_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "db_password")
+	_ = ioutil.WriteFile(secretFile, []byte("from-file\n"), 0600)
+	_ = os.Setenv("RATGO_TEST_SECRET", "from-env")
+	defer os.Unsetenv("RATGO_TEST_SECRET")
+
+	cases := map[string]string{
+		"plain":                                              "plain",
+		"${env:RATGO_TEST_SECRET}":                           "from-env",
+		"user:${env:RATGO_TEST_SECRET}@host":                 "user:from-env@host",
+		"${file:" + secretFile + "}":                         "from-file",
+		"$env:RATGO_TEST_SECRET":                             "$env:RATGO_TEST_SECRET",
+		"${env:RATGO_TEST_SECRET}${file:" + secretFile + "}": "from-envfrom-file",
+	}
+	for value, expect := range cases {
+		if resolved, _, err := resolveSecret(value); err != nil || resolved != expect {
+			t.Errorf("'%s' expect '%s', but receive '%s' %v", value, expect, resolved, err)
+		}
+	}
+	for _, value := range []string{"${env:RATGO_TEST_MISSING}", "${file:" + filepath.Join(dir, "missing") + "}"} {
+		if _, _, err := resolveSecret(value); err == nil {
+			t.Errorf("'%s' expect error", value)
+		}
+	}
+}
+
+func TestConfigMasked_01(t *testing.T) {
+	_ = os.Setenv(ConfigSecretKeyEnv, base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")))
+	defer os.Unsetenv(ConfigSecretKeyEnv)
+	_ = os.Setenv("RATGO_TEST_SECRET", "from-env")
+	defer os.Unsetenv("RATGO_TEST_SECRET")
+	ciphertext, _ := EncryptConfigValue("p@ss")
+	cs, cleanup := testConfigStorage(t, map[string]string{
+		"config/redis.yml": "master:\n  Host: 127.0.0.1:6379\n  Password: " + ciphertext + "\n  User: ${env:RATGO_TEST_SECRET}\n",
+	})
+	defer cleanup()
+	cs.Init()
+
+	if cs.Get("redis.master.Password").ToString() != "p@ss" || cs.Get("redis.master.User").ToString() != "from-env" {
+		t.Fatal(cs.Get("redis").Value())
+	}
+	masked, _ := cs.Masked("redis").(map[string]interface{})
+	master, _ := masked["master"].(map[string]interface{})
+	if master["Password"] != configSecretMask || master["User"] != configSecretMask || master["Host"] != "127.0.0.1:6379" {
+		t.Fatal(masked)
+	}
+	if cs.Masked("redis.master.Password") != configSecretMask {
+		t.FailNow()
+	}
+
+	// 原配置不受影响
+	if cs.Get("redis.master.Password").ToString() != "p@ss" {
+		t.FailNow()
+	}
+}
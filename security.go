@@ -0,0 +1,317 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/utils/types"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 跨域配置, 对应 ratgo 配置 cors 节点
+type CorsOption struct {
+	Enable           bool
+	AllowOrigins     []string // 允许的来源, 支持 * 及 https://*.example.com
+	AllowMethods     []string // 允许的请求方法
+	AllowHeaders     []string // 允许的请求头, 为空时回显预检请求头
+	ExposeHeaders    []string // 暴露的响应头
+	AllowCredentials bool     // 是否允许携带凭证, 开启后回显 Origin, 须配置明确的来源, 不可与 * 同时使用
+	MaxAge           int      // 预检结果缓存时间(秒)
+}
+
+// 安全响应头配置, 对应 ratgo 配置 secure 节点
+type SecureOption struct {
+	Enable                bool
+	FrameOptions          string // X-Frame-Options
+	ContentTypeNosniff    bool   // X-Content-Type-Options: nosniff
+	XSSProtection         string // X-XSS-Protection
+	ContentSecurityPolicy string // Content-Security-Policy
+	ReferrerPolicy        string // Referrer-Policy
+	HSTSMaxAge            int    // Strict-Transport-Security max-age(秒), 0 不发送, 仅https请求发送
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+}
+
+// 请求体大小限制配置, 对应 ratgo 配置 bodyLimit 节点
+type BodyLimitOption struct {
+	Enable   bool
+	MaxBytes int64 // 最大字节数
+}
+
+// 默认跨域配置
+func DefaultCorsOption() CorsOption {
+	return CorsOption{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{http.MethodHead, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		MaxAge:       600,
+	}
+}
+
+// 默认安全响应头配置
+func DefaultSecureOption() SecureOption {
+	return SecureOption{
+		FrameOptions:       "SAMEORIGIN",
+		ContentTypeNosniff: true,
+		XSSProtection:      "1; mode=block",
+		ReferrerPolicy:     "strict-origin-when-cross-origin",
+	}
+}
+
+// 默认请求体大小限制配置
+func DefaultBodyLimitOption() BodyLimitOption {
+	return BodyLimitOption{MaxBytes: 10 << 20}
+}
+
+// 根据 ratgo 配置注册安全相关中间件(安全响应头、跨域、请求体限制、限流), 各节点 Enable 为 true 时生效
+func RegisterSecurityMiddleWare() error {
+	secureOption := DefaultSecureOption()
+	if loadOption(&secureOption, Config.GetApp("secure").ToAnyMap()); secureOption.Enable {
+		MiddleWare.SetGlobal(SecureMiddleWare(secureOption))
+	}
+	corsOption := DefaultCorsOption()
+	if loadOption(&corsOption, Config.GetApp("cors").ToAnyMap()); corsOption.Enable {
+		if corsOption.AllowCredentials && corsAllowAll(corsOption.AllowOrigins) {
+			return errors.New("cors 'AllowCredentials' can't be used with '*' in 'AllowOrigins', configure explicit origins instead")
+		}
+		MiddleWare.SetGlobal(CorsMiddleWare(corsOption))
+	}
+	bodyLimitOption := DefaultBodyLimitOption()
+	if loadOption(&bodyLimitOption, Config.GetApp("bodyLimit").ToAnyMap()); bodyLimitOption.Enable {
+		MiddleWare.SetGlobal(BodyLimitMiddleWare(bodyLimitOption))
+	}
+	rateLimitOption := DefaultRateLimitOption()
+	if loadOption(&rateLimitOption, Config.GetApp("rateLimit").ToAnyMap()); rateLimitOption.Enable {
+		handlerFunc, err := RateLimitMiddleWare(rateLimitOption)
+		if err != nil {
+			return err
+		}
+		MiddleWare.SetGlobal(handlerFunc)
+	}
+	return nil
+}
+
+// 设置可信代理, 未配置 TrustedProxies 时不信任任何代理, ClientIP 为连接地址
+func setTrustedProxies(engine *gin.Engine) error {
+	return engine.SetTrustedProxies(optionStrings(Config.TrustedProxies))
+}
+
+// 客户端ip, 未配置 TrustedProxies 时使用连接地址, 不解析可伪造的 X-Forwarded-For 等请求头
+func clientIP(context *gin.Context) string {
+	if Config.TrustedProxies == "" {
+		return context.RemoteIP()
+	}
+	return context.ClientIP()
+}
+
+// 校验请求头 Authorization: Bearer token, 常量时间比较
+func bearerAuthorized(context *gin.Context, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(context.GetHeader("Authorization")), []byte("Bearer "+token)) == 1
+}
+
+// 跨域中间件, AllowOrigins 含 * 时响应 *, 不回显 Origin 及允许携带凭证
+func CorsMiddleWare(option CorsOption) gin.HandlerFunc {
+	allowMethods := strings.Join(normalizeMethods(option.AllowMethods), ", ")
+	allowHeaders := strings.Join(option.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(option.ExposeHeaders, ", ")
+	allowAll := corsAllowAll(option.AllowOrigins)
+	return func(context *gin.Context) {
+		origin := context.GetHeader("Origin")
+		if origin == "" { // 非跨域请求
+			context.Next()
+			return
+		}
+		context.Writer.Header().Add("Vary", "Origin")
+		preflight := context.Request.Method == http.MethodOptions && context.GetHeader("Access-Control-Request-Method") != ""
+
+		// 来源校验
+		if !allowAll && !originAllowed(option.AllowOrigins, origin) {
+			if preflight {
+				context.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			context.Next()
+			return
+		}
+		if allowAll {
+			context.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			context.Header("Access-Control-Allow-Origin", origin)
+			if option.AllowCredentials {
+				context.Header("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		// 预检请求
+		if preflight {
+			context.Header("Access-Control-Allow-Methods", allowMethods)
+			if allowHeaders != "" {
+				context.Header("Access-Control-Allow-Headers", allowHeaders)
+			} else if requestHeaders := context.GetHeader("Access-Control-Request-Headers"); requestHeaders != "" {
+				context.Header("Access-Control-Allow-Headers", requestHeaders)
+			}
+			if option.MaxAge > 0 {
+				context.Header("Access-Control-Max-Age", strconv.Itoa(option.MaxAge))
+			}
+			context.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		if exposeHeaders != "" {
+			context.Header("Access-Control-Expose-Headers", exposeHeaders)
+		}
+		context.Next()
+	}
+}
+
+// 是否允许全部来源
+func corsAllowAll(allowOrigins []string) bool {
+	for _, origin := range allowOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// 来源是否允许, 支持子域名通配 https://*.example.com
+func originAllowed(allowOrigins []string, origin string) bool {
+	for _, allowOrigin := range allowOrigins {
+		if allowOrigin == origin {
+			return true
+		}
+		if index := strings.Index(allowOrigin, "*"); index >= 0 {
+			prefix, suffix := allowOrigin[:index], allowOrigin[index+1:]
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 安全响应头中间件
+func SecureMiddleWare(option SecureOption) gin.HandlerFunc {
+	hsts := ""
+	if option.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", option.HSTSMaxAge)
+		if option.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if option.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	return func(context *gin.Context) {
+		header := context.Writer.Header()
+		if option.FrameOptions != "" {
+			header.Set("X-Frame-Options", option.FrameOptions)
+		}
+		if option.ContentTypeNosniff {
+			header.Set("X-Content-Type-Options", "nosniff")
+		}
+		if option.XSSProtection != "" {
+			header.Set("X-XSS-Protection", option.XSSProtection)
+		}
+		if option.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", option.ContentSecurityPolicy)
+		}
+		if option.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", option.ReferrerPolicy)
+		}
+		if hsts != "" && (context.Request.TLS != nil || context.GetHeader("X-Forwarded-Proto") == "https") {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		context.Next()
+	}
+}
+
+// 请求体大小限制中间件, 超出时响应 413
+func BodyLimitMiddleWare(option BodyLimitOption) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if option.MaxBytes <= 0 || context.Request.Body == nil {
+			context.Next()
+			return
+		}
+		if context.Request.ContentLength > option.MaxBytes {
+			context.Abort()
+			Render(context, NewResult().SetStatus(http.StatusRequestEntityTooLarge).SetMsg("Request Entity Too Large"))
+			return
+		}
+		context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, option.MaxBytes)
+		context.Next()
+	}
+}
+
+// 使用配置填充选项结构体, 支持 string、int、bool、[]string(逗号分隔或列表) 类型字段
+func loadOption(option interface{}, conf types.AnyMap) {
+	pt := reflect.TypeOf(option).Elem()
+	pv := reflect.ValueOf(option).Elem()
+	for i := 0; i < pt.NumField(); i++ {
+		name := pt.Field(i).Name
+		value := conf.Get(name)
+		if value == nil || value.Value() == nil {
+			continue
+		}
+		pf := pv.Field(i)
+		switch pf.Kind() {
+		case reflect.String:
+			pf.SetString(value.ToString())
+		case reflect.Int, reflect.Int64:
+			pf.SetInt(optionInt(value.Value()))
+		case reflect.Bool:
+			pf.SetBool(value.ToBool())
+		case reflect.Slice:
+			if pf.Type().Elem().Kind() == reflect.String {
+				pf.Set(reflect.ValueOf(optionStrings(value.Value())))
+			}
+		}
+	}
+}
+
+// 配置值转 int64, 兼容 yaml、json、ini 解析后的数值类型
+func optionInt(value interface{}) int64 {
+	switch value.(type) {
+	case int:
+		return int64(value.(int))
+	case int64:
+		return value.(int64)
+	case float64:
+		return int64(value.(float64))
+	case string:
+		v, _ := strconv.ParseInt(strings.TrimSpace(value.(string)), 10, 64)
+		return v
+	}
+	return 0
+}
+
+// 配置值转 []string
+func optionStrings(value interface{}) []string {
+	result := make([]string, 0)
+	switch value.(type) {
+	case string:
+		for _, v := range strings.Split(value.(string), ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result = append(result, v)
+			}
+		}
+	case []string:
+		result = append(result, value.([]string)...)
+	case []interface{}:
+		for _, v := range value.([]interface{}) {
+			result = append(result, fmt.Sprint(v))
+		}
+	}
+	return result
+}
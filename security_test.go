@@ -0,0 +1,143 @@
+package ratgo
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/utils/types"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestOriginAllowed_01(t *testing.T) {
+	allowOrigins := []string{"https://admin.example.com", "https://*.example.org"}
+	cases := map[string]bool{
+		"https://admin.example.com":  true,
+		"https://api.example.org":    true,
+		"https://a.b.example.org":    true,
+		"https://example.org":        false,
+		"http://api.example.org":     false,
+		"https://admin.example.com.": false,
+		"https://evil.com":           false,
+	}
+	for origin, expect := range cases {
+		if originAllowed(allowOrigins, origin) != expect {
+			t.Errorf("origin '%s' expect %v", origin, expect)
+		}
+	}
+}
+
+func TestCorsMiddleWare_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	request := func(option CorsOption, method string, origin string) *httptest.ResponseRecorder {
+		engine := gin.New()
+		engine.Use(CorsMiddleWare(option))
+		engine.Handle(method, "/", func(context *gin.Context) {
+			context.String(http.StatusOK, "ok")
+		})
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// 明确来源回显 Origin 并允许凭证
+	option := DefaultCorsOption()
+	option.AllowOrigins = []string{"https://*.example.com"}
+	option.AllowCredentials = true
+	recorder := request(option, http.MethodGet, "https://app.example.com")
+	if recorder.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || recorder.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatal(recorder.Header())
+	}
+
+	// 未允许的来源预检返回 403
+	if recorder = request(option, http.MethodOptions, "https://evil.com"); recorder.Code != http.StatusForbidden {
+		t.Fatal(recorder.Code)
+	}
+
+	// * 不回显 Origin 及凭证
+	option.AllowOrigins = []string{"*"}
+	recorder = request(option, http.MethodGet, "https://evil.com")
+	if recorder.Header().Get("Access-Control-Allow-Origin") != "*" || recorder.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatal(recorder.Header())
+	}
+}
+
+func TestCorsMiddleWare_02(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	preflight := func(option CorsOption, header map[string]string) *httptest.ResponseRecorder {
+		engine := gin.New()
+		engine.Use(CorsMiddleWare(option))
+		engine.POST("/", func(context *gin.Context) {
+			context.String(http.StatusOK, "ok")
+		})
+		header["Origin"] = "https://app.example.com"
+		header["Access-Control-Request-Method"] = http.MethodPost
+		return testServe(engine, http.MethodOptions, "/", header)
+	}
+
+	// 预检返回允许的方法、请求头及缓存时间, 不执行后续 handler
+	option := CorsOption{AllowOrigins: []string{"https://app.example.com"}, AllowMethods: []string{"get", "post", "POST"}, AllowHeaders: []string{"Authorization", "X-Api-Key"}, MaxAge: 600}
+	recorder := preflight(option, map[string]string{"Access-Control-Request-Headers": "X-Other"})
+	header := recorder.Header()
+	if recorder.Code != http.StatusNoContent || recorder.Body.Len() != 0 {
+		t.Fatal(recorder.Code, recorder.Body.String())
+	}
+	if header.Get("Access-Control-Allow-Methods") != "HEAD, GET, POST" || header.Get("Access-Control-Allow-Headers") != "Authorization, X-Api-Key" ||
+		header.Get("Access-Control-Max-Age") != "600" || header.Get("Vary") != "Origin" {
+		t.Fatal(header)
+	}
+
+	// 未配置 AllowHeaders 时回显预检请求头, MaxAge 为 0 时不发送
+	option.AllowHeaders, option.MaxAge = nil, 0
+	header = preflight(option, map[string]string{"Access-Control-Request-Headers": "X-Other, Content-Type"}).Header()
+	if header.Get("Access-Control-Allow-Headers") != "X-Other, Content-Type" || header.Get("Access-Control-Max-Age") != "" {
+		t.Fatal(header)
+	}
+	header = preflight(option, map[string]string{}).Header()
+	if _, ok := header["Access-Control-Allow-Headers"]; ok {
+		t.Fatal(header)
+	}
+}
+
+type testOption struct {
+	Enable   bool
+	Name     string
+	MaxAge   int
+	MaxBytes int64
+	Origins  []string
+	Methods  []string
+	Skip     []int
+}
+
+func TestLoadOption_01(t *testing.T) {
+	// 兼容 yaml、json、ini 解析后的值类型, 未配置的字段保留默认值
+	cases := []struct {
+		conf   types.AnyMap
+		expect testOption
+	}{
+		{
+			types.AnyMap{"Enable": true, "Name": "api", "MaxAge": 60, "MaxBytes": int64(1024), "Origins": []interface{}{"a", "b"}, "Methods": []string{"GET"}},
+			testOption{Enable: true, Name: "api", MaxAge: 60, MaxBytes: 1024, Origins: []string{"a", "b"}, Methods: []string{"GET"}},
+		},
+		{
+			types.AnyMap{"Enable": "true", "MaxAge": float64(30), "MaxBytes": " 2048 ", "Origins": "a, b,,c", "Skip": []interface{}{1}},
+			testOption{Enable: true, Name: "default", MaxAge: 30, MaxBytes: 2048, Origins: []string{"a", "b", "c"}, Methods: []string{"POST"}},
+		},
+		{
+			types.AnyMap{"Enable": false, "MaxAge": "x", "Origins": ""},
+			testOption{Name: "default", Origins: []string{}, Methods: []string{"POST"}},
+		},
+	}
+	for _, c := range cases {
+		option := testOption{Name: "default", Methods: []string{"POST"}}
+		loadOption(&option, c.conf)
+		if !reflect.DeepEqual(option, c.expect) {
+			t.Errorf("%v expect %+v, but receive %+v", c.conf, c.expect, option)
+		}
+	}
+}
@@ -0,0 +1,612 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/ext"
+	"github.com/vdongchina/ratgo/utils/types"
+	"github.com/vdongchina/ratgo/utils/vdlog"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gin.Context 中存储 session 的key
+const sessionKey = "session"
+
+// session 数据中存储闪存消息的key
+const sessionFlashKey = "_flash"
+
+// session配置, 对应 ratgo 配置 session 节点
+type SessionOption struct {
+	Enable   bool
+	Cookie   string // cookie名称, 默认 ratgo_sid
+	Secret   string // cookie签名密钥, 必填
+	TTL      int    // 过期时间(秒), 每次访问后顺延
+	Store    string // 存储: memory | file | redis
+	Path     string // file 存储目录, 默认 runtime/session
+	Redis    string // redis配置标识, 对应 ext.Redis.Pool(identify)
+	Prefix   string // redis key前缀
+	Domain   string // cookie domain
+	Secure   bool   // cookie secure
+	SameSite string // cookie SameSite: lax | strict | none
+}
+
+// session存储
+type SessionStore interface {
+	Load(id string) (map[string]interface{}, error)                       // 不存在或已过期时返回 nil, nil
+	Save(id string, data map[string]interface{}, ttl time.Duration) error // 保存并重置过期时间
+	Touch(id string, ttl time.Duration) error                             // 顺延过期时间
+	Delete(id string) error
+}
+
+// 默认session配置
+func DefaultSessionOption() SessionOption {
+	return SessionOption{
+		Cookie:   "ratgo_sid",
+		TTL:      7200,
+		Store:    "memory",
+		Prefix:   "ratgo:sess:",
+		SameSite: "lax",
+	}
+}
+
+// 根据 ratgo 配置注册 session 中间件, session 节点 Enable 为 true 时生效
+func RegisterSessionMiddleWare() error {
+	option := DefaultSessionOption()
+	if loadOption(&option, Config.GetApp("session").ToAnyMap()); !option.Enable {
+		return nil
+	}
+	var store SessionStore
+	switch option.Store {
+	case "", "memory":
+		store = NewMemorySessionStore()
+	case "file":
+		if option.Path == "" {
+			option.Path = filepath.Join(Config.RuntimePath, "session")
+		}
+		fileStore, err := NewFileSessionStore(option.Path)
+		if err != nil {
+			return err
+		}
+		store = fileStore
+	case "redis":
+		if option.Redis == "" {
+			return errors.New("session redis store requires 'Redis' identify")
+		}
+		store = NewRedisSessionStore(ext.Redis.Pool(option.Redis), option.Prefix)
+	default:
+		return errors.New(fmt.Sprintf("unsupported session store '%s'", option.Store))
+	}
+	manager, err := NewSessionManager(option, store)
+	if err != nil {
+		return err
+	}
+	MiddleWare.SetGlobal(manager.MiddleWare())
+	return nil
+}
+
+// 获取 session, 未注册 session 中间件时返回 nil
+func GetSession(context *gin.Context) *Session {
+	if value, ok := context.Get(sessionKey); ok {
+		if session, ok := value.(*Session); ok {
+			return session
+		}
+	}
+	return nil
+}
+
+/**************************************** 管理器 ****************************************/
+// session管理器
+type SessionManager struct {
+	option SessionOption
+	store  SessionStore
+}
+
+// 创建session管理器
+func NewSessionManager(option SessionOption, store SessionStore) (*SessionManager, error) {
+	if option.Secret == "" {
+		return nil, errors.New("session 'Secret' can't be empty")
+	}
+	if option.Cookie == "" {
+		option.Cookie = "ratgo_sid"
+	}
+	if option.TTL <= 0 {
+		option.TTL = 7200
+	}
+	return &SessionManager{option: option, store: store}, nil
+}
+
+// session中间件: 读取 cookie 加载 session, 请求结束后保存或顺延过期时间
+func (sm *SessionManager) MiddleWare() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		session := &Session{manager: sm, context: context, data: map[string]interface{}{}}
+		if cookie, err := context.Cookie(sm.option.Cookie); err == nil {
+			if id, ok := sm.verify(cookie); ok {
+				data, err := sm.store.Load(id)
+				if err != nil { // 读取失败时使用空 session
+					sessionLog(context, fmt.Sprintf("load session failed. error:%v", err))
+				} else if data != nil {
+					session.id, session.data = id, data
+					sm.setCookie(context, id, sm.option.TTL) // 顺延 cookie 过期时间
+				}
+			}
+		}
+		context.Set(sessionKey, session)
+		context.Next()
+
+		if err := session.save(); err != nil {
+			sessionLog(context, fmt.Sprintf("save session failed. error:%v", err))
+		}
+	}
+}
+
+// 记录 session 错误日志, 优先使用请求日志对象
+func sessionLog(context *gin.Context, msg string) {
+	if logger, ok := context.Get("logger"); ok {
+		logger.(*vdlog.Logger).Error(msg)
+	} else {
+		vdlog.StdLogger.Error(msg)
+	}
+}
+
+// 签名 session id
+func (sm *SessionManager) sign(id string) string {
+	mac := hmac.New(sha256.New, []byte(sm.option.Secret))
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// 校验 cookie 签名
+func (sm *SessionManager) verify(cookie string) (string, bool) {
+	index := strings.LastIndex(cookie, ".")
+	if index <= 0 {
+		return "", false
+	}
+	id := cookie[:index]
+	return id, hmac.Equal([]byte(sm.sign(id)), []byte(cookie))
+}
+
+// 写入 cookie, maxAge < 0 时删除
+func (sm *SessionManager) setCookie(context *gin.Context, id string, maxAge int) {
+	value := ""
+	if maxAge >= 0 {
+		value = sm.sign(id)
+	}
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(sm.option.SameSite) {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+	// 同一请求多次写入时覆盖之前的 cookie
+	header := context.Writer.Header()
+	cookies := header.Values("Set-Cookie")
+	header.Del("Set-Cookie")
+	for _, cookie := range cookies {
+		if !strings.HasPrefix(cookie, sm.option.Cookie+"=") {
+			header.Add("Set-Cookie", cookie)
+		}
+	}
+	http.SetCookie(context.Writer, &http.Cookie{
+		Name:     sm.option.Cookie,
+		Value:    value,
+		Path:     "/",
+		Domain:   sm.option.Domain,
+		MaxAge:   maxAge,
+		Secure:   sm.option.Secure || context.Request.TLS != nil,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
+
+// 生成 session id
+func newSessionId() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+/**************************************** Session ****************************************/
+// 单次请求的 session
+type Session struct {
+	sync.Mutex
+	manager   *SessionManager
+	context   *gin.Context
+	id        string
+	oldId     string // Regenerate 前的 id, 保存时删除
+	data      map[string]interface{}
+	changed   bool
+	destroyed bool
+}
+
+// session id, 新 session 首次写入前为空
+func (s *Session) Id() string {
+	return s.id
+}
+
+// 读取
+func (s *Session) Get(key string) *types.AnyValue {
+	s.Lock()
+	defer s.Unlock()
+	return types.Eval(s.data[key])
+}
+
+// 写入
+func (s *Session) Set(key string, value interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	s.data[key] = value
+	s.touch()
+}
+
+// 删除
+func (s *Session) Delete(key string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.data, key)
+	s.touch()
+}
+
+// 清空数据
+func (s *Session) Clear() {
+	s.Lock()
+	defer s.Unlock()
+	s.data = map[string]interface{}{}
+	s.touch()
+}
+
+// 写入闪存消息, 读取一次后删除
+func (s *Session) Flash(key string, value interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	flash, _ := s.data[sessionFlashKey].(map[string]interface{})
+	if flash == nil {
+		flash = map[string]interface{}{}
+	}
+	values, _ := flash[key].([]interface{})
+	flash[key] = append(values, value)
+	s.data[sessionFlashKey] = flash
+	s.touch()
+}
+
+// 读取并删除闪存消息
+func (s *Session) Flashes(key string) []interface{} {
+	s.Lock()
+	defer s.Unlock()
+	flash, _ := s.data[sessionFlashKey].(map[string]interface{})
+	values, ok := flash[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	if delete(flash, key); len(flash) == 0 {
+		delete(s.data, sessionFlashKey)
+	}
+	s.touch()
+	return values
+}
+
+// 更换 session id 并保留数据, 用于登录后防止会话固定
+func (s *Session) Regenerate() {
+	s.Lock()
+	defer s.Unlock()
+	if s.oldId == "" {
+		s.oldId = s.id
+	}
+	s.id = ""
+	s.touch()
+}
+
+// 销毁 session 并删除 cookie
+func (s *Session) Destroy() {
+	s.Lock()
+	defer s.Unlock()
+	s.data = map[string]interface{}{}
+	s.destroyed = true
+	s.manager.setCookie(s.context, "", -1)
+}
+
+// 标记修改, 新 session 首次写入时生成 id 并写入 cookie(需在响应写入前调用)
+// 响应已写入时无法写入 cookie, 记录错误日志且不保存新 session
+func (s *Session) touch() {
+	s.changed = true
+	s.destroyed = false
+	if s.id == "" {
+		if s.context.Writer.Written() {
+			sessionLog(s.context, "set session cookie failed. the response has been written")
+			return
+		}
+		s.id = newSessionId()
+		s.manager.setCookie(s.context, s.id, s.manager.option.TTL)
+	}
+}
+
+// 保存
+func (s *Session) save() error {
+	s.Lock()
+	defer s.Unlock()
+	store := s.manager.store
+	ttl := time.Duration(s.manager.option.TTL) * time.Second
+	if s.oldId != "" && s.oldId != s.id {
+		if err := store.Delete(s.oldId); err != nil {
+			return err
+		}
+	}
+	switch {
+	case s.destroyed && s.id != "":
+		return store.Delete(s.id)
+	case s.changed && s.id != "":
+		return store.Save(s.id, s.data, ttl)
+	case s.id != "":
+		return store.Touch(s.id, ttl)
+	}
+	return nil
+}
+
+/**************************************** 内存存储 ****************************************/
+// 内存session项
+type memorySessionItem struct {
+	data   []byte
+	expire time.Time
+}
+
+// 内存session存储, 单进程有效
+type MemorySessionStore struct {
+	sync.Mutex
+	items     map[string]*memorySessionItem
+	lastClean time.Time
+}
+
+// 创建内存session存储
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{items: map[string]*memorySessionItem{}, lastClean: time.Now()}
+}
+
+// 读取
+func (ms *MemorySessionStore) Load(id string) (map[string]interface{}, error) {
+	ms.Lock()
+	item, ok := ms.items[id]
+	ms.Unlock()
+	if !ok || time.Now().After(item.expire) {
+		return nil, nil
+	}
+	return decodeSession(item.data)
+}
+
+// 保存
+func (ms *MemorySessionStore) Save(id string, data map[string]interface{}, ttl time.Duration) error {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	ms.Lock()
+	defer ms.Unlock()
+	now := time.Now()
+	if now.Sub(ms.lastClean) > time.Minute { // 每分钟清理过期数据
+		ms.lastClean = now
+		for key, item := range ms.items {
+			if now.After(item.expire) {
+				delete(ms.items, key)
+			}
+		}
+	}
+	ms.items[id] = &memorySessionItem{data: buf, expire: now.Add(ttl)}
+	return nil
+}
+
+// 顺延过期时间
+func (ms *MemorySessionStore) Touch(id string, ttl time.Duration) error {
+	ms.Lock()
+	defer ms.Unlock()
+	if item, ok := ms.items[id]; ok {
+		item.expire = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+// 删除
+func (ms *MemorySessionStore) Delete(id string) error {
+	ms.Lock()
+	defer ms.Unlock()
+	delete(ms.items, id)
+	return nil
+}
+
+/**************************************** 文件存储 ****************************************/
+// 文件session存储, 每个 session 一个文件, 通过文件修改时间判断过期
+type FileSessionStore struct {
+	sync.Mutex
+	dir       string
+	lastClean time.Time
+}
+
+// 创建文件session存储
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &FileSessionStore{dir: dir, lastClean: time.Now()}, nil
+}
+
+// session 文件路径, id 仅允许十六进制字符
+func (fs *FileSessionStore) filename(id string) (string, error) {
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return "", errors.New(fmt.Sprintf("invalid session id '%s'", id))
+	}
+	return filepath.Join(fs.dir, "sess_"+id), nil
+}
+
+// 读取
+func (fs *FileSessionStore) Load(id string) (map[string]interface{}, error) {
+	filename, err := fs.filename(id)
+	if err != nil {
+		return nil, nil
+	}
+	fs.Lock()
+	defer fs.Unlock()
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if time.Now().After(info.ModTime()) { // 修改时间即过期时间
+		_ = os.Remove(filename)
+		return nil, nil
+	}
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSession(buf)
+}
+
+// 保存
+func (fs *FileSessionStore) Save(id string, data map[string]interface{}, ttl time.Duration) error {
+	filename, err := fs.filename(id)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	fs.Lock()
+	defer fs.Unlock()
+	fs.clean()
+	if err := ioutil.WriteFile(filename, buf, 0600); err != nil {
+		return err
+	}
+	expire := time.Now().Add(ttl)
+	return os.Chtimes(filename, expire, expire)
+}
+
+// 顺延过期时间
+func (fs *FileSessionStore) Touch(id string, ttl time.Duration) error {
+	filename, err := fs.filename(id)
+	if err != nil {
+		return err
+	}
+	fs.Lock()
+	defer fs.Unlock()
+	expire := time.Now().Add(ttl)
+	if err := os.Chtimes(filename, expire, expire); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// 删除
+func (fs *FileSessionStore) Delete(id string) error {
+	filename, err := fs.filename(id)
+	if err != nil {
+		return nil
+	}
+	fs.Lock()
+	defer fs.Unlock()
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// 每分钟清理过期文件
+func (fs *FileSessionStore) clean() {
+	now := time.Now()
+	if now.Sub(fs.lastClean) < time.Minute {
+		return
+	}
+	fs.lastClean = now
+	files, _ := filepath.Glob(filepath.Join(fs.dir, "sess_*"))
+	for _, filename := range files {
+		if info, err := os.Stat(filename); err == nil && now.After(info.ModTime()) {
+			_ = os.Remove(filename)
+		}
+	}
+}
+
+/**************************************** redis存储 ****************************************/
+// redis session存储
+type RedisSessionStore struct {
+	pool   *redis.Pool
+	prefix string
+}
+
+// 创建redis session存储
+func NewRedisSessionStore(pool *redis.Pool, prefix string) *RedisSessionStore {
+	return &RedisSessionStore{pool: pool, prefix: prefix}
+}
+
+// 读取
+func (rs *RedisSessionStore) Load(id string) (map[string]interface{}, error) {
+	conn := rs.pool.Get()
+	defer func() { _ = conn.Close() }()
+	buf, err := redis.Bytes(conn.Do("GET", rs.prefix+id))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return decodeSession(buf)
+}
+
+// 保存
+func (rs *RedisSessionStore) Save(id string, data map[string]interface{}, ttl time.Duration) error {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	conn := rs.pool.Get()
+	defer func() { _ = conn.Close() }()
+	_, err = conn.Do("SET", rs.prefix+id, buf, "PX", int64(ttl/time.Millisecond))
+	return err
+}
+
+// 顺延过期时间
+func (rs *RedisSessionStore) Touch(id string, ttl time.Duration) error {
+	conn := rs.pool.Get()
+	defer func() { _ = conn.Close() }()
+	_, err := conn.Do("PEXPIRE", rs.prefix+id, int64(ttl/time.Millisecond))
+	return err
+}
+
+// 删除
+func (rs *RedisSessionStore) Delete(id string) error {
+	conn := rs.pool.Get()
+	defer func() { _ = conn.Close() }()
+	_, err := conn.Do("DEL", rs.prefix+id)
+	return err
+}
+
+// 解码 session 数据
+func decodeSession(buf []byte) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	if err := json.Unmarshal(buf, &data); err != nil {
+		return nil, errors.New(fmt.Sprintf("decode session failed. error:%v", err))
+	}
+	return data, nil
+}
@@ -0,0 +1,175 @@
+package ratgo
+
+import (
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionSign_01(t *testing.T) {
+	manager, err := NewSessionManager(SessionOption{Secret: "secret"}, NewMemorySessionStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := manager.sign("abc123")
+	if id, ok := manager.verify(cookie); !ok || id != "abc123" {
+		t.Fatal(id, ok)
+	}
+	for _, forged := range []string{"abc123", "abc124" + cookie[6:], cookie + "x", "." + cookie[7:]} {
+		if _, ok := manager.verify(forged); ok {
+			t.Errorf("forged cookie '%s' passed", forged)
+		}
+	}
+	other, _ := NewSessionManager(SessionOption{Secret: "other"}, NewMemorySessionStore())
+	if _, ok := other.verify(cookie); ok {
+		t.FailNow()
+	}
+}
+
+func TestSessionFlash_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager, _ := NewSessionManager(SessionOption{Secret: "secret"}, NewMemorySessionStore())
+	engine := gin.New()
+	engine.Use(manager.MiddleWare())
+	engine.GET("/set", func(context *gin.Context) {
+		GetSession(context).Flash("notice", "saved")
+		context.String(http.StatusOK, "ok")
+	})
+	engine.GET("/get", func(context *gin.Context) {
+		context.JSON(http.StatusOK, GetSession(context).Flashes("notice"))
+	})
+	engine.GET("/late", func(context *gin.Context) {
+		context.String(http.StatusOK, "ok")
+		GetSession(context).Set("uid", 1) // 响应已写入, 无法写入 cookie
+	})
+	request := func(path string, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	cookie := strings.Split(request("/set", "").Header().Get("Set-Cookie"), ";")[0]
+	if !strings.HasPrefix(cookie, "ratgo_sid=") {
+		t.Fatal(cookie)
+	}
+	if body := request("/get", cookie).Body.String(); body != `["saved"]` {
+		t.Fatal(body)
+	}
+	if body := request("/get", cookie).Body.String(); body != "null" {
+		t.Fatal(body)
+	}
+
+	// 篡改的 cookie 使用空 session
+	if body := request("/get", cookie+"x").Body.String(); body != "null" {
+		t.Fatal(body)
+	}
+	if recorder := request("/late", ""); recorder.Header().Get("Set-Cookie") != "" {
+		t.Fatal(recorder.Header())
+	}
+}
+
+func TestFileSessionStore_01(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ratgo_session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewFileSessionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := newSessionId()
+	if err := store.Save(id, map[string]interface{}{"uid": "1001"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := store.Load(id); err != nil || data["uid"] != "1001" {
+		t.Fatal(data, err)
+	}
+
+	// 非十六进制 id 不访问文件
+	for _, invalid := range []string{"", "../sess_" + id, "/etc/passwd", id + "/x"} {
+		if err := store.Save(invalid, map[string]interface{}{}, time.Minute); err == nil {
+			t.Errorf("save invalid id '%s' passed", invalid)
+		}
+		if data, err := store.Load(invalid); data != nil || err != nil {
+			t.Errorf("load invalid id '%s' receive %v %v", invalid, data, err)
+		}
+	}
+
+	// 过期
+	if err := store.Save(id, map[string]interface{}{"uid": "1001"}, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := store.Load(id); data != nil {
+		t.Fatal(data)
+	}
+}
+
+func TestSessionAuthenticator_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir, err := ioutil.TempDir("", "ratgo_session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, _ := NewFileSessionStore(dir)
+	manager, _ := NewSessionManager(SessionOption{Secret: "secret"}, store)
+	authenticator := &SessionAuthenticator{}
+	engine := gin.New()
+	engine.Use(manager.MiddleWare())
+	engine.POST("/login", func(context *gin.Context) {
+		if err := authenticator.Login(context, &Principal{Id: "1001", Roles: []string{"admin"}}); err != nil {
+			t.Error(err)
+		}
+		context.String(http.StatusOK, "ok")
+	})
+	engine.GET("/me", AuthMiddleWare(AuthPolicy{Authenticators: []Authenticator{authenticator}, Roles: []string{"admin"}}), func(context *gin.Context) {
+		principal, _ := GetPrincipal(context)
+		context.String(http.StatusOK, principal.Id+":"+principal.Type+":"+GetSession(context).Id())
+	})
+	engine.POST("/logout", func(context *gin.Context) {
+		_ = authenticator.Logout(context)
+		context.String(http.StatusOK, "ok")
+	})
+	request := func(method string, path string, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if code := request(http.MethodGet, "/me", "").Code; code != http.StatusUnauthorized {
+		t.Fatal(code)
+	}
+
+	// 登录写入 SessionManager 的签名 cookie, 认证主体与 session 一致
+	cookie := strings.Split(request(http.MethodPost, "/login", "").Header().Get("Set-Cookie"), ";")[0]
+	id, ok := manager.verify(strings.TrimPrefix(cookie, "ratgo_sid="))
+	if !ok {
+		t.Fatal(cookie)
+	}
+	if recorder := request(http.MethodGet, "/me", cookie); recorder.Code != http.StatusOK || recorder.Body.String() != "1001:session:"+id {
+		t.Fatal(recorder.Code, recorder.Body.String())
+	}
+
+	// 篡改签名及登出后认证失败
+	if code := request(http.MethodGet, "/me", cookie+"x").Code; code != http.StatusUnauthorized {
+		t.Fatal(code)
+	}
+	request(http.MethodPost, "/logout", cookie)
+	if code := request(http.MethodGet, "/me", cookie).Code; code != http.StatusUnauthorized {
+		t.Fatal(code)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// 证书文件检查间隔
+const certCheckInterval = 5 * time.Second
+
+// 证书加载器, 证书文件修改后自动重新加载, 无需重启服务
+type CertLoader struct {
+	lock      sync.RWMutex
+	certFile  string
+	keyFile   string
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkTime time.Time
+}
+
+// 获取证书加载器
+func NewCertLoader(certFile, keyFile string) (*CertLoader, error) {
+	cl := &CertLoader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := cl.Reload(); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// 从磁盘重新加载证书
+func (cl *CertLoader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(cl.certFile, cl.keyFile)
+	if err != nil {
+		return errors.New(fmt.Sprintf("load certificate '%s' failed. error:%v", cl.certFile, err))
+	}
+	modTime, err := cl.lastModTime()
+	if err != nil {
+		return err
+	}
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	cl.cert = &cert
+	cl.modTime = modTime
+	cl.checkTime = time.Now()
+	return nil
+}
+
+// 用于 tls.Config.GetCertificate
+func (cl *CertLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cl.lock.RLock()
+	cert, modTime, checkTime := cl.cert, cl.modTime, cl.checkTime
+	cl.lock.RUnlock()
+	if time.Since(checkTime) < certCheckInterval {
+		return cert, nil
+	}
+
+	// 检查文件是否修改
+	cl.lock.Lock()
+	cl.checkTime = time.Now()
+	cl.lock.Unlock()
+	if lastModTime, err := cl.lastModTime(); err == nil && lastModTime.After(modTime) {
+		if err := cl.Reload(); err != nil {
+			fmt.Printf("[WebServer]重新加载证书失败, 继续使用原证书 error: %v \r\n", err)
+		} else {
+			fmt.Printf("[WebServer]证书已重新加载: %s \r\n", cl.certFile)
+		}
+	}
+	cl.lock.RLock()
+	defer cl.lock.RUnlock()
+	return cl.cert, nil
+}
+
+// 证书及私钥文件最后修改时间
+func (cl *CertLoader) lastModTime() (modTime time.Time, err error) {
+	for _, file := range []string{cl.certFile, cl.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTime, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+// http重定向至https handler
+func httpsRedirectHandler(httpsAddr string) http.Handler {
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
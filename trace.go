@@ -0,0 +1,116 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratgo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/utils/encrypt"
+	"github.com/vdongchina/ratgo/utils/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"path/filepath"
+	"time"
+)
+
+// 链路追踪配置, 对应 ratgo 配置 trace 节点
+type TraceOption struct {
+	Enable      bool
+	Exporter    string // 导出方式: stdout | file, 其他 exporter 通过 trace.Setup 设置
+	File        string // file 导出文件, 默认 runtime/log/trace.log
+	ServiceName string // 服务名称, 默认 AppName
+	SampleRate  int    // 采样率(百分比), 默认 100
+}
+
+// 链路追踪中间件优先级, 先于日志等中间件执行
+const traceMiddleWarePriority = 1000
+
+// 根据 ratgo 配置初始化链路追踪, 已通过 trace.Setup 设置时跳过
+func initTracer(tag string) {
+	option := TraceOption{Exporter: "stdout", SampleRate: 100}
+	if loadOption(&option, Config.GetApp("trace").ToAnyMap()); !option.Enable || trace.Enabled() {
+		return
+	}
+	if option.ServiceName == "" {
+		option.ServiceName = Config.AppName
+	}
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch option.Exporter {
+	case "stdout":
+		exporter, err = trace.NewStdoutExporter()
+	case "file":
+		if option.File == "" {
+			option.File = filepath.Join(Config.RuntimeLogPath, "trace.log")
+		}
+		exporter, err = trace.NewFileExporter(option.File)
+	default:
+		err = errors.New(fmt.Sprintf("unsupported trace exporter '%s'", option.Exporter))
+	}
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("[%s]链路追踪 exporter: %s \r\n", tag, option.Exporter)
+	trace.Setup(option.ServiceName, exporter, float64(option.SampleRate)/100)
+}
+
+// 注册链路追踪中间件: 读取 traceparent、X-Request-Id, 创建服务端 span 并回写响应头
+func RegisterTraceMiddleWare() error {
+	MiddleWare.SetGlobalPriority(traceMiddleWarePriority, func(context *gin.Context) {
+		request := context.Request
+		ctx := trace.Extract(request.Context(), request.Header)
+		ctx, span := trace.Start(ctx, request.Method+" "+request.URL.Path,
+			oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+			oteltrace.WithAttributes(
+				attribute.String("http.method", request.Method),
+				attribute.String("http.target", request.RequestURI),
+				attribute.String("http.client_ip", context.ClientIP()),
+			),
+		)
+		defer span.End()
+
+		// 请求ID: 请求头 X-Request-Id(格式有效时) > trace id > 生成
+		requestId := trace.RequestId(ctx)
+		if requestId == "" {
+			if requestId = trace.TraceId(ctx); requestId == "" {
+				requestId = encrypt.Md5(request.RequestURI, fmt.Sprintf("%d", time.Now().UnixNano()))
+			}
+			ctx = trace.WithRequestId(ctx, requestId)
+		}
+		context.Set("requestId", requestId)
+		context.Request = request.WithContext(ctx)
+		trace.Inject(ctx, context.Writer.Header()) // 回写 traceparent、X-Request-Id
+
+		context.Next()
+
+		status := context.Writer.Status()
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		if route := context.FullPath(); route != "" {
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", status))
+		}
+	})
+	return nil
+}
+
+// 控制器 span
+func traceController(context *gin.Context, controller ControllerInterface) oteltrace.Span {
+	name := controllerName(controller)
+	ctx, span := trace.Start(context.Request.Context(), "controller "+name,
+		oteltrace.WithAttributes(attribute.String("ratgo.controller", name)),
+	)
+	context.Request = context.Request.WithContext(ctx)
+	return span
+}
@@ -0,0 +1,45 @@
+package ratgo
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/utils/trace"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTraceMiddleWare_01(t *testing.T) {
+	ws, restore := testWebServer()
+	defer restore()
+	_ = RegisterTraceMiddleWare()
+	ws.gin.Use(MiddleWare.GetGlobal()...)
+	ws.gin.GET("/trace", func(context *gin.Context) {
+		context.String(http.StatusOK, context.GetString("requestId")+","+trace.RequestId(context.Request.Context()))
+	})
+	traceparent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	cases := []struct {
+		header map[string]string
+		expect string
+	}{
+		{map[string]string{"X-Request-Id": "req-1"}, "req-1"},
+		{map[string]string{"X-Request-Id": "req-1", "Traceparent": traceparent}, "req-1"},
+		{map[string]string{"X-Request-Id": "bad id", "Traceparent": traceparent}, "0af7651916cd43dd8448eb211c80319c"}, // 无效请求ID时使用 trace id
+	}
+	for _, c := range cases {
+		recorder := testServe(ws.gin, http.MethodGet, "/trace", c.header)
+		if recorder.Body.String() != c.expect+","+c.expect || recorder.Header().Get("X-Request-Id") != c.expect {
+			t.Errorf("%v receive %s %v", c.header, recorder.Body.String(), recorder.Header())
+		}
+		if c.header["Traceparent"] != "" && !strings.Contains(recorder.Header().Get("Traceparent"), "0af7651916cd43dd8448eb211c80319c") {
+			t.Errorf("%v receive %v", c.header, recorder.Header())
+		}
+	}
+
+	// 无请求ID及 trace 时生成
+	recorder := testServe(ws.gin, http.MethodGet, "/trace", map[string]string{"X-Request-Id": strings.Repeat("a", 65)})
+	requestId := recorder.Header().Get("X-Request-Id")
+	if !trace.ValidRequestId(requestId) || requestId == strings.Repeat("a", 65) || recorder.Body.String() != requestId+","+requestId {
+		t.Errorf("receive %s %v", recorder.Body.String(), recorder.Header())
+	}
+}
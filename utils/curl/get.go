@@ -2,7 +2,12 @@ package curl
 
 import (
 	"bytes"
+	"context"
+	"github.com/vdongchina/ratgo/utils/trace"
 	"github.com/vdongchina/ratgo/utils/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"io/ioutil"
 	"net/http"
 	url2 "net/url"
@@ -12,6 +17,7 @@ import (
 type GetCaller struct {
 	method string
 	header map[string]string
+	ctx    context.Context
 }
 
 // 获取一个GetCaller
@@ -27,6 +33,12 @@ func (gc *GetCaller) Header(header map[string]string) {
 	gc.header = header
 }
 
+// 设置链路追踪 context, 请求头携带 traceparent、X-Request-Id
+func (gc *GetCaller) WithContext(ctx context.Context) *GetCaller {
+	gc.ctx = ctx
+	return gc
+}
+
 // 发送Get请求
 func (gc *GetCaller) Call(url string, args ...map[string]interface{}) *types.AnyValue {
 	// 参数处理
@@ -58,7 +70,7 @@ func (gc *GetCaller) Call(url string, args ...map[string]interface{}) *types.Any
 		}
 	}
 	// 发送一个GET请求
-	resp, err3 := http.DefaultClient.Do(request)
+	resp, err3 := do(gc.ctx, request)
 	if err3 != nil {
 		return types.Eval(err3)
 	}
@@ -71,4 +83,25 @@ func (gc *GetCaller) Call(url string, args ...map[string]interface{}) *types.Any
 		respData = bytes.TrimPrefix(respData, []byte("\xef\xbb\xbf"))
 	}
 	return types.Eval(respData)
-}
\ No newline at end of file
+}
+
+// 发送请求, 设置 context 时记录客户端 span 并传递链路信息
+func do(ctx context.Context, request *http.Request) (*http.Response, error) {
+	if ctx == nil {
+		return http.DefaultClient.Do(request)
+	}
+	ctx, span := trace.Start(ctx, "HTTP "+request.Method,
+		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+		oteltrace.WithAttributes(attribute.String("http.method", request.Method), attribute.String("http.url", request.URL.String())),
+	)
+	defer span.End()
+	trace.Inject(ctx, request.Header)
+	resp, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	return resp, nil
+}
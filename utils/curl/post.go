@@ -2,6 +2,7 @@ package curl
 
 import (
 	"bytes"
+	"context"
 	"github.com/vdongchina/ratgo/utils/types"
 	"io"
 	"io/ioutil"
@@ -13,6 +14,7 @@ import (
 type PostCaller struct {
 	method string
 	header map[string]string
+	ctx    context.Context
 }
 
 // 获取一个PostCaller
@@ -37,6 +39,12 @@ func (pc *PostCaller) GetHeader(key string) string {
 	return ""
 }
 
+// 设置链路追踪 context, 请求头携带 traceparent、X-Request-Id
+func (pc *PostCaller) WithContext(ctx context.Context) *PostCaller {
+	pc.ctx = ctx
+	return pc
+}
+
 // Send a post request.
 func (pc *PostCaller) Call(url string, args ...interface{}) *types.AnyValue {
 	// 创建request
@@ -53,7 +61,7 @@ func (pc *PostCaller) Call(url string, args ...interface{}) *types.AnyValue {
 	}
 
 	// 发送一个POST请求
-	resp, err2 := http.DefaultClient.Do(request)
+	resp, err2 := do(pc.ctx, request)
 	if err2 != nil {
 		return types.Eval(err2)
 	}
@@ -0,0 +1,171 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 指标命名空间
+const namespace = "ratgo"
+
+var (
+	// 指标注册器, 自定义指标可通过 Registry.MustRegister 注册
+	Registry *prometheus.Registry
+
+	// http 请求数
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	// http 请求耗时
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency in seconds by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// 数据库查询耗时
+	DBDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Dao query latency in seconds by database, operation and result.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"db", "operation", "result"})
+)
+
+// 初始化注册器, 包含 go 运行时及进程指标
+func init() {
+	Registry = prometheus.NewRegistry()
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		DBDuration,
+		pools,
+	)
+}
+
+// 指标 http handler, Prometheus text 格式
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// 记录 http 请求
+func ObserveHTTP(method string, route string, status int, duration time.Duration) {
+	HTTPRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	HTTPDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// 记录数据库查询
+func ObserveDB(db string, operation string, duration time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	DBDuration.WithLabelValues(db, operation, result).Observe(duration.Seconds())
+}
+
+/**************************************** 连接池指标 ****************************************/
+// redis 连接池, garyburd/redigo 及 gomodule/redigo 的 *redis.Pool 均已实现
+type RedisPool interface {
+	ActiveCount() int
+	IdleCount() int
+}
+
+// 连接池来源, 采集时调用, 返回 标识 => 连接池
+type (
+	SQLSource   func() map[string]*sql.DB
+	RedisSource func() map[string]RedisPool
+)
+
+// 注册 sql.DB 连接池来源, 相同 name 覆盖
+func RegisterSQLSource(name string, source SQLSource) {
+	pools.lock.Lock()
+	defer pools.lock.Unlock()
+	pools.sql[name] = source
+}
+
+// 注册 redis 连接池来源, 相同 name 覆盖
+func RegisterRedisSource(name string, source RedisSource) {
+	pools.lock.Lock()
+	defer pools.lock.Unlock()
+	pools.redis[name] = source
+}
+
+// 连接池指标采集器
+type poolCollector struct {
+	lock  sync.RWMutex
+	sql   map[string]SQLSource
+	redis map[string]RedisSource
+}
+
+var pools = &poolCollector{
+	sql:   map[string]SQLSource{},
+	redis: map[string]RedisSource{},
+}
+
+// 指标描述
+var (
+	sqlLabels          = []string{"source", "db"}
+	redisLabels        = []string{"source", "pool"}
+	sqlMaxOpenDesc     = prometheus.NewDesc(namespace+"_sql_max_open_connections", "Maximum number of open connections to the database.", sqlLabels, nil)
+	sqlOpenDesc        = prometheus.NewDesc(namespace+"_sql_open_connections", "The number of established connections both in use and idle.", sqlLabels, nil)
+	sqlInUseDesc       = prometheus.NewDesc(namespace+"_sql_in_use_connections", "The number of connections currently in use.", sqlLabels, nil)
+	sqlIdleDesc        = prometheus.NewDesc(namespace+"_sql_idle_connections", "The number of idle connections.", sqlLabels, nil)
+	sqlWaitCountDesc   = prometheus.NewDesc(namespace+"_sql_wait_count_total", "The total number of connections waited for.", sqlLabels, nil)
+	sqlWaitSecondsDesc = prometheus.NewDesc(namespace+"_sql_wait_duration_seconds_total", "The total time blocked waiting for a new connection.", sqlLabels, nil)
+	redisActiveDesc    = prometheus.NewDesc(namespace+"_redis_pool_active_connections", "The number of connections in the pool, both in use and idle.", redisLabels, nil)
+	redisIdleDesc      = prometheus.NewDesc(namespace+"_redis_pool_idle_connections", "The number of idle connections in the pool.", redisLabels, nil)
+)
+
+// 实现 prometheus.Collector
+func (pc *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{sqlMaxOpenDesc, sqlOpenDesc, sqlInUseDesc, sqlIdleDesc, sqlWaitCountDesc, sqlWaitSecondsDesc, redisActiveDesc, redisIdleDesc} {
+		ch <- desc
+	}
+}
+
+// 实现 prometheus.Collector
+func (pc *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	pc.lock.RLock()
+	defer pc.lock.RUnlock()
+	for source, sqlSource := range pc.sql {
+		for name, db := range sqlSource() {
+			stats := db.Stats()
+			ch <- prometheus.MustNewConstMetric(sqlMaxOpenDesc, prometheus.GaugeValue, float64(stats.MaxOpenConnections), source, name)
+			ch <- prometheus.MustNewConstMetric(sqlOpenDesc, prometheus.GaugeValue, float64(stats.OpenConnections), source, name)
+			ch <- prometheus.MustNewConstMetric(sqlInUseDesc, prometheus.GaugeValue, float64(stats.InUse), source, name)
+			ch <- prometheus.MustNewConstMetric(sqlIdleDesc, prometheus.GaugeValue, float64(stats.Idle), source, name)
+			ch <- prometheus.MustNewConstMetric(sqlWaitCountDesc, prometheus.CounterValue, float64(stats.WaitCount), source, name)
+			ch <- prometheus.MustNewConstMetric(sqlWaitSecondsDesc, prometheus.CounterValue, stats.WaitDuration.Seconds(), source, name)
+		}
+	}
+	for source, redisSource := range pc.redis {
+		for name, pool := range redisSource() {
+			ch <- prometheus.MustNewConstMetric(redisActiveDesc, prometheus.GaugeValue, float64(pool.ActiveCount()), source, name)
+			ch <- prometheus.MustNewConstMetric(redisIdleDesc, prometheus.GaugeValue, float64(pool.IdleCount()), source, name)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 ratgo Author. All Rights Reserved.
+// Licensed under the Apache License, Version 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package trace
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// 请求ID请求头
+const RequestIdHeader = "X-Request-Id"
+
+// 请求ID格式: 最长64位, 仅含字母、数字及 -_.
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9\-_.]{1,64}$`)
+
+// 埋点名称
+const instrumentationName = "github.com/vdongchina/ratgo"
+
+// context 中存储请求ID的key
+type requestIdKey struct{}
+
+var (
+	lock     sync.Mutex
+	provider *sdktrace.TracerProvider
+)
+
+// 默认使用 W3C traceparent、baggage 传播
+func init() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// 初始化 TracerProvider, exporter 可使用任意 OpenTelemetry SpanExporter(如 otlptrace), sampleRate 取值 0~1
+func Setup(serviceName string, exporter sdktrace.SpanExporter, sampleRate float64) {
+	lock.Lock()
+	defer lock.Unlock()
+	if provider != nil {
+		_ = provider.Shutdown(context.Background())
+	}
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRate))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+}
+
+// 是否已初始化
+func Enabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return provider != nil
+}
+
+// 导出剩余 span 并关闭
+func Shutdown() error {
+	lock.Lock()
+	defer lock.Unlock()
+	if provider == nil {
+		return nil
+	}
+	err := provider.Shutdown(context.Background())
+	provider = nil
+	return err
+}
+
+// 开始一个 span, 未初始化时为空操作
+func Start(ctx context.Context, name string, opts ...oteltrace.SpanStartOption) (context.Context, oteltrace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// 存储请求ID
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// 获取请求ID
+func RequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+// 获取 trace id, 无有效 span 时返回空
+func TraceId(ctx context.Context) string {
+	if spanContext := oteltrace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		return spanContext.TraceID().String()
+	}
+	return ""
+}
+
+// 请求ID格式是否有效
+func ValidRequestId(requestId string) bool {
+	return requestIdPattern.MatchString(requestId)
+}
+
+// 从请求头读取 traceparent、X-Request-Id, 忽略格式无效的 X-Request-Id
+func Extract(ctx context.Context, header http.Header) context.Context {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+	if requestId := header.Get(RequestIdHeader); ValidRequestId(requestId) {
+		ctx = WithRequestId(ctx, requestId)
+	}
+	return ctx
+}
+
+// 写入 traceparent、X-Request-Id 至请求头(或响应头)
+func Inject(ctx context.Context, header http.Header) {
+	if ctx == nil {
+		return
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+	if requestId := RequestId(ctx); requestId != "" {
+		header.Set(RequestIdHeader, requestId)
+	}
+}
+
+// 标准输出 exporter
+func NewStdoutExporter() (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithPrettyPrint())
+}
+
+// 文件 exporter, 每行一个 span(json)
+func NewFileExporter(filename string) (sdktrace.SpanExporter, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &fileExporter{SpanExporter: exporter, file: file}, nil
+}
+
+// 文件 exporter, 关闭时释放文件句柄
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+// 关闭
+func (fe *fileExporter) Shutdown(ctx context.Context) error {
+	err := fe.SpanExporter.Shutdown(ctx)
+	if closeErr := fe.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
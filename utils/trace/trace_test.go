@@ -0,0 +1,52 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidRequestId_01(t *testing.T) {
+	cases := map[string]bool{
+		"":                        false,
+		"abc-123_x.y":             true,
+		strings.Repeat("a", 64):   true,
+		strings.Repeat("a", 65):   false,
+		"a b":                     false,
+		"a\r\nX-Injected: 1":      false,
+		"<script>":                false,
+		"0af7651916cd43dd8448eb2": true,
+	}
+	for requestId, expect := range cases {
+		if ValidRequestId(requestId) != expect {
+			t.Errorf("'%s' expect %v", requestId, expect)
+		}
+	}
+}
+
+func TestExtract_01(t *testing.T) {
+	// 忽略格式无效的 X-Request-Id
+	ctx := Extract(context.Background(), http.Header{RequestIdHeader: {"bad id\n"}})
+	if RequestId(ctx) != "" {
+		t.Fatal(RequestId(ctx))
+	}
+
+	// 读取 traceparent 及 X-Request-Id, 并回写
+	header := http.Header{
+		"Traceparent":   {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+		RequestIdHeader: {"req-1"},
+	}
+	ctx = Extract(context.Background(), header)
+	if RequestId(ctx) != "req-1" || TraceId(ctx) != "0af7651916cd43dd8448eb211c80319c" {
+		t.Fatal(RequestId(ctx), TraceId(ctx))
+	}
+	output := http.Header{}
+	Inject(ctx, output)
+	if output.Get(RequestIdHeader) != "req-1" || output.Get("Traceparent") != header.Get("Traceparent") {
+		t.Fatal(output)
+	}
+	if RequestId(nil) != "" || TraceId(context.Background()) != "" {
+		t.Fatal("expect empty")
+	}
+}
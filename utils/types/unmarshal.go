@@ -0,0 +1,383 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Struct tags used by Unmarshal.
+//
+//	config:   key name in source map, "-" to skip, default is the field name (matched case-insensitively)
+//	default:  value used when the key is missing or empty
+//	validate: comma separated rules: required, min=N, max=N, oneof=a|b|c
+//	unit:     unit of numeric values for time.Duration fields: ns, us, ms, s, m, h (default ns)
+const (
+	TagConfig   = "config"
+	TagDefault  = "default"
+	TagValidate = "validate"
+	TagUnit     = "unit"
+)
+
+// Validator is called after a struct has been filled, its error is appended to UnmarshalErrors.
+type Validator interface {
+	Validate() error
+}
+
+// All errors collected by Unmarshal.
+type UnmarshalErrors []error
+
+// Error joins all errors.
+func (ue UnmarshalErrors) Error() string {
+	messages := make([]string, 0, len(ue))
+	for _, err := range ue {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add an error of field path.
+func (ue *UnmarshalErrors) add(path string, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if path != "" {
+		message = path + ": " + message
+	}
+	*ue = append(*ue, errors.New(message))
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Duration units.
+var durationUnits = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// Unmarshal src (map[string]interface{}, map[string]string) into the struct pointed to by out,
+// apply defaults and validate rules, all errors are returned as UnmarshalErrors.
+func Unmarshal(src interface{}, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New(fmt.Sprintf("unmarshal target must be a non-nil struct pointer, got %T", out))
+	}
+	errs := UnmarshalErrors{}
+	unmarshalStruct(src, rv.Elem(), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Unmarshal value into the struct pointed to by out.
+func (av *AnyValue) Unmarshal(out interface{}) error {
+	return Unmarshal(av.value, out)
+}
+
+// Fill struct fields.
+func unmarshalStruct(src interface{}, rv reflect.Value, path string, errs *UnmarshalErrors) {
+	source := map[string]interface{}{}
+	switch src.(type) {
+	case nil:
+	case map[string]interface{}:
+		source = src.(map[string]interface{})
+	case map[string]string:
+		for key, value := range src.(map[string]string) {
+			source[key] = value
+		}
+	default:
+		errs.add(strings.TrimPrefix(path, "."), "expect map, got %T", src)
+		return
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag := field.Tag.Get(TagConfig); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		fieldPath := strings.TrimPrefix(path+"."+name, ".")
+		value, ok := lookup(source, name)
+		if !ok || isEmpty(value) {
+			if defaultValue, has := field.Tag.Lookup(TagDefault); has {
+				value, ok = defaultValue, true
+			}
+		}
+
+		fv := rv.Field(i)
+		if ok && !isEmpty(value) {
+			if err := setValue(fv, value, field.Tag.Get(TagUnit), fieldPath, errs); err != nil {
+				errs.add(fieldPath, "%v", err)
+				continue
+			}
+		} else if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			unmarshalStruct(nil, fv, fieldPath, errs)
+		}
+		validate(fv, ok && !isEmpty(value), field.Tag.Get(TagValidate), fieldPath, errs)
+	}
+
+	// Custom validation.
+	if validator, ok := rv.Addr().Interface().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			errs.add(strings.TrimPrefix(path, "."), "%v", err)
+		}
+	}
+}
+
+// Find key, exact match first, then case-insensitive.
+func lookup(source map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := source[name]; ok {
+		return value, true
+	}
+	for key, value := range source {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Nil or empty string.
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s) == ""
+	}
+	return false
+}
+
+// Convert value to field type.
+func setValue(fv reflect.Value, value interface{}, unit string, path string, errs *UnmarshalErrors) error {
+	if fv.Type() == durationType {
+		duration, err := toDuration(value, unit)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(duration))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(fv.Type().Elem())
+		if err := setValue(elem.Elem(), value, unit, path, errs); err != nil {
+			return err
+		}
+		fv.Set(elem)
+	case reflect.Struct:
+		unmarshalStruct(value, fv, path, errs)
+	case reflect.String:
+		fv.SetString(fmt.Sprint(value))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(fmt.Sprint(value)))
+		if err != nil {
+			return errors.New(fmt.Sprintf("expect bool, got '%v'", value))
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, err := toFloat(value)
+		if err != nil || f != float64(int64(f)) || fv.OverflowInt(int64(f)) {
+			return errors.New(fmt.Sprintf("expect %s, got '%v'", fv.Kind(), value))
+		}
+		fv.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, err := toFloat(value)
+		if err != nil || f < 0 || f != float64(uint64(f)) || fv.OverflowUint(uint64(f)) {
+			return errors.New(fmt.Sprintf("expect %s, got '%v'", fv.Kind(), value))
+		}
+		fv.SetUint(uint64(f))
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat(value)
+		if err != nil {
+			return errors.New(fmt.Sprintf("expect %s, got '%v'", fv.Kind(), value))
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		items := toSlice(value)
+		slice := reflect.MakeSlice(fv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), item, unit, path+"."+strconv.Itoa(i), errs); err != nil {
+				return errors.New(fmt.Sprintf("index %d: %v", i, err))
+			}
+		}
+		fv.Set(slice)
+	case reflect.Map:
+		if fv.Type().Key().Kind() != reflect.String {
+			return errors.New(fmt.Sprintf("unsupported type %s", fv.Type()))
+		}
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			if stringMap, isStringMap := value.(map[string]string); isStringMap {
+				source = map[string]interface{}{}
+				for key, item := range stringMap {
+					source[key] = item
+				}
+			} else {
+				return errors.New(fmt.Sprintf("expect map, got %T", value))
+			}
+		}
+		m := reflect.MakeMapWithSize(fv.Type(), len(source))
+		for key, item := range source {
+			elem := reflect.New(fv.Type().Elem()).Elem()
+			if err := setValue(elem, item, unit, path+"."+key, errs); err != nil {
+				return errors.New(fmt.Sprintf("key '%s': %v", key, err))
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(fv.Type().Key()), elem)
+		}
+		fv.Set(m)
+	case reflect.Interface:
+		fv.Set(reflect.ValueOf(value))
+	default:
+		return errors.New(fmt.Sprintf("unsupported type %s", fv.Type()))
+	}
+	return nil
+}
+
+// Convert value to float64, accept numbers and numeric strings.
+func toFloat(value interface{}) (float64, error) {
+	switch value.(type) {
+	case int:
+		return float64(value.(int)), nil
+	case int64:
+		return float64(value.(int64)), nil
+	case uint64:
+		return float64(value.(uint64)), nil
+	case float64:
+		return value.(float64), nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(value.(string)), 64)
+	}
+	return strconv.ParseFloat(fmt.Sprint(value), 64)
+}
+
+// Convert value to time.Duration, strings like "500ms" are parsed by time.ParseDuration,
+// numbers are multiplied by unit.
+func toDuration(value interface{}, unit string) (time.Duration, error) {
+	multiple, ok := durationUnits[unit]
+	if !ok {
+		return 0, errors.New(fmt.Sprintf("unknown duration unit '%s'", unit))
+	}
+	if f, err := toFloat(value); err == nil {
+		return time.Duration(f * float64(multiple)), nil
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(fmt.Sprint(value)))
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("expect duration, got '%v'", value))
+	}
+	return duration, nil
+}
+
+// Convert value to slice, comma separated strings are split.
+func toSlice(value interface{}) []interface{} {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return items
+	case reflect.String:
+		items := make([]interface{}, 0)
+		for _, item := range strings.Split(rv.String(), ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	return []interface{}{value}
+}
+
+// Validate field by rules.
+func validate(fv reflect.Value, present bool, rules string, path string, errs *UnmarshalErrors) {
+	if rules == "" {
+		return
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg := strings.TrimSpace(rule), ""
+		if index := strings.Index(name, "="); index >= 0 {
+			name, arg = name[:index], name[index+1:]
+		}
+		switch name {
+		case "required":
+			if !present {
+				errs.add(path, "is required")
+				return
+			}
+		case "min", "max":
+			limit, err := parseLimit(fv, arg)
+			if err != nil {
+				errs.add(path, "invalid rule '%s'", rule)
+				continue
+			}
+			size, ok := measure(fv)
+			if !ok || !present {
+				continue
+			}
+			if name == "min" && size < limit {
+				errs.add(path, "must be >= %s, got %v", arg, fv.Interface())
+			} else if name == "max" && size > limit {
+				errs.add(path, "must be <= %s, got %v", arg, fv.Interface())
+			}
+		case "oneof":
+			if !present {
+				continue
+			}
+			options := strings.Split(arg, "|")
+			value := fmt.Sprint(fv.Interface())
+			matched := false
+			for _, option := range options {
+				matched = matched || option == value
+			}
+			if !matched {
+				errs.add(path, "must be one of [%s], got '%s'", strings.Join(options, " "), value)
+			}
+		default:
+			errs.add(path, "unknown rule '%s'", rule)
+		}
+	}
+}
+
+// Limit of range rules, durations are written like min=1s.
+func parseLimit(fv reflect.Value, arg string) (float64, error) {
+	if fv.Type() == durationType {
+		duration, err := toDuration(arg, "")
+		return float64(duration), err
+	}
+	return strconv.ParseFloat(arg, 64)
+}
+
+// Numeric value of field for range rules, length for strings, slices and maps.
+func measure(fv reflect.Value) (float64, bool) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), true
+	case reflect.String, reflect.Slice, reflect.Map:
+		return float64(fv.Len()), true
+	case reflect.Ptr:
+		if fv.IsNil() {
+			return 0, false
+		}
+		return measure(fv.Elem())
+	}
+	return 0, false
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type testPool struct {
+	MaxIdle int           `validate:"min=0,max=100"`
+	Timeout time.Duration `unit:"ms" default:"3s"`
+}
+
+type testOption struct {
+	Host     string   `validate:"required"`
+	Port     int      `default:"6379"`
+	Mode     string   `default:"single" validate:"oneof=single|sentinel"`
+	Hosts    []string `config:"HostArray"`
+	Lifetime time.Duration
+	Pool     testPool
+	Enable   *bool
+	Ignore   string `config:"-"`
+}
+
+func TestUnmarshal_01(t *testing.T) {
+	option := testOption{}
+	err := Unmarshal(map[string]interface{}{
+		"host":      "127.0.0.1",
+		"HostArray": "10.0.0.1:26379, 10.0.0.2:26379",
+		"Lifetime":  "500ms",
+		"Pool":      map[string]interface{}{"MaxIdle": "16", "Timeout": 200},
+		"Enable":    true,
+		"Ignore":    "x",
+	}, &option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(option)
+	if option.Host != "127.0.0.1" || option.Port != 6379 || option.Mode != "single" || option.Ignore != "" {
+		t.FailNow()
+	}
+	if len(option.Hosts) != 2 || option.Hosts[1] != "10.0.0.2:26379" {
+		t.FailNow()
+	}
+	if option.Lifetime != 500*time.Millisecond || option.Pool.MaxIdle != 16 || option.Pool.Timeout != 200*time.Millisecond {
+		t.FailNow()
+	}
+	if option.Enable == nil || !*option.Enable {
+		t.FailNow()
+	}
+
+	// Defaults of missing nested struct.
+	option = testOption{}
+	if err = Unmarshal(map[string]string{"Host": "127.0.0.1"}, &option); err != nil || option.Pool.Timeout != 3*time.Second {
+		t.Fatal(err, option.Pool.Timeout)
+	}
+}
+
+func TestUnmarshal_02(t *testing.T) {
+	option := testOption{}
+	err := Unmarshal(map[string]interface{}{
+		"Port":     "abc",
+		"Mode":     "cluster",
+		"Lifetime": "1x",
+		"Pool":     map[string]interface{}{"MaxIdle": 200},
+	}, &option)
+	errs, ok := err.(UnmarshalErrors)
+	if !ok || len(errs) != 5 {
+		t.Fatal(err)
+	}
+	t.Log(err)
+	for _, expect := range []string{"Host: is required", "Port: expect int", "Mode: must be one of", "Lifetime: expect duration", "Pool.MaxIdle: must be <= 100"} {
+		if !strings.Contains(err.Error(), expect) {
+			t.Fatalf("expect '%s' in '%v'", expect, err)
+		}
+	}
+}
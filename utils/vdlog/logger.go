@@ -26,6 +26,13 @@ import (
 var (
 	// std is the name of the standard logger in stdlib `log`
 	StdLogger = NewLogger(defaultConfig)
+
+	// StdLogger 读写锁
+	stdLock sync.RWMutex
+
+	// 日志文件句柄, 相同文件复用同一句柄
+	fileLock    sync.Mutex
+	fileHandles = map[string]*os.File{}
 )
 
 // 日志结构体
@@ -42,10 +49,18 @@ type Logger struct {
 
 // 使用 StdLogger
 func Use(outConfig interface{}) *Logger {
-	StdLogger = NewLogger(outConfig)
+	logger := NewLogger(outConfig)
+	stdLock.Lock()
+	defer stdLock.Unlock()
+	StdLogger = logger
 	return StdLogger
 }
 
+// 重新加载配置, 替换 StdLogger, 已克隆的 logger 继续使用原配置
+func Reload(outConfig interface{}) *Logger {
+	return Use(outConfig)
+}
+
 // 根据类型获取
 func NewLogger(outConfig interface{}) *Logger {
 	var config Config
@@ -86,6 +101,8 @@ func NewLogger(outConfig interface{}) *Logger {
 
 // 克隆
 func Clone() *Logger {
+	stdLock.RLock()
+	defer stdLock.RUnlock()
 	logger := &Logger{
 		Date:        time.Now().Format("20060102"),
 		Config:      StdLogger.Config,
@@ -177,7 +194,36 @@ func (ls *Logger) ioWriter(level string, date string) (osFile *os.File, err erro
 	paths := []string{date, level}
 	baseName := fmt.Sprintf("%s.%s", strings.Join(paths, "-"), ls.Config.Ext)
 	filename := path.Join(ls.Config.RootPath, baseName)
-	return os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+	return openFile(filename)
+}
+
+// 打开日志文件, 已打开的文件直接返回句柄
+func openFile(filename string) (*os.File, error) {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	if osFile, ok := fileHandles[filename]; ok {
+		return osFile, nil
+	}
+	osFile, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+	if err != nil {
+		return nil, err
+	}
+	fileHandles[filename] = osFile
+	return osFile, nil
+}
+
+// 关闭全部日志文件句柄
+func Close() error {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	var closeErr error
+	for filename, osFile := range fileHandles {
+		if err := osFile.Close(); err != nil {
+			closeErr = errors.New(fmt.Sprintf("close file '%s' failed. error:%v", filename, err))
+		}
+	}
+	fileHandles = map[string]*os.File{}
+	return closeErr
 }
 
 // 记录警告信息
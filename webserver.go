@@ -10,15 +10,19 @@
 package ratgo
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/vdongchina/ratgo/ext"
-	"github.com/vdongchina/ratgo/extend"
-	"github.com/vdongchina/ratgo/extend/cache"
-	"github.com/vdongchina/ratgo/utils/types"
 	"github.com/vdongchina/ratgo/utils/vdlog"
 	"net/http"
+	"os"
+	"os/signal"
 	"reflect"
+	"strings"
+	"syscall"
+	"time"
 )
 
 // Error handle.
@@ -26,7 +30,8 @@ type ErrorHandle func()
 
 // Web server.
 type WebServer struct {
-	gin *gin.Engine
+	gin     *gin.Engine
+	servers []*http.Server
 }
 
 // 获取web实例
@@ -38,62 +43,133 @@ func NewWebServer() *WebServer {
 
 // 初始化
 func (ws *WebServer) Init() {
-	// 配置初始化
-	fmt.Printf("[WebServer初始化]系统配置初始化...\r\n")
-	Config.Init()
-
-	// 配置处理
-	if Config.HandleFunc != nil {
-		fmt.Printf("[WebServer初始化]系统配置经过应用处理...\r\n")
-		err := Config.HandleFunc(Config)
-		if err != nil {
-			panic(err)
-		}
+	tag := "WebServer初始化"
+	initConfig(tag)   // 配置初始化
+	initResource(tag) // 初始化 mysql、redis
+	if initLogger() { // 系统日志
+		_ = RegisterLogMiddleWare() // 日志中间件
+	}
+	initReload(tag) // 配置热加载
+	if err := setTrustedProxies(ws.gin); err != nil { // 可信代理
+		panic(err)
 	}
 
-	// 初始化 mysql
-	if Config.InitDb == true {
-		dbConfig := Config.Get("database").ToAnyMap()
-		fmt.Printf("[WebServer初始化]数据库对象 config: %v \r\n", dbConfig)
-		extend.Gorm.Init(dbConfig)
-		ext.GormV2.Init(dbConfig)
+	// 链路追踪、监控指标、压缩、安全、session、认证中间件
+	_ = RegisterTraceMiddleWare()
+	_ = RegisterMetricsMiddleWare()
+	_ = RegisterCompressMiddleWare()
+	if err := RegisterSecurityMiddleWare(); err != nil { // 安全相关中间件
+		panic(err)
+	}
+	if err := RegisterSessionMiddleWare(); err != nil { // session中间件
+		panic(err)
 	}
+	if err := RegisterAuthMiddleWare(); err != nil { // 分组认证策略
+		panic(err)
+	}
+	runUserFunc() // 执行用户挂载函数
+}
 
-	// 初始化 redis
-	if Config.InitRedis == true {
-		redisConfig := Config.Get("redis").ToAnyMap()
-		fmt.Printf("[WebServer初始化]redis对象 config: %v \r\n", redisConfig)
-		cache.Redis.Init(redisConfig)
-		ext.Redis.Init(redisConfig)
+// 运行server, 收到 SIGINT/SIGTERM 信号后优雅关闭
+func (ws *WebServer) Run() {
+	_ = ws.registerMiddleWare() // 注册中间件
+	_ = ws.registerRouter()     // 注册路由
+	_ = ws.registerStatic()     // 注册静态文件
+	_ = ws.registerAdmin()      // 注册管理接口
+	_ = ws.registerMetrics()    // 注册指标接口
+	_ = ws.registerHealth()     // 注册健康检查接口
+	runOnStart()                // 执行服务启动函数
+
+	// 运行http、https服务
+	errChan := make(chan error, 2)
+	servers, err := listen("WebServer", ws.gin, errChan)
+	if err != nil {
+		panic(err)
 	}
+	ws.servers = servers
 
-	// 系统日志
-	logConfig := types.AnyMap(Config.Get("ratgo-log.main").ToAnyMap())
-	if logConfig.Get("Turn").ToString() == "on" {
-		if logConfig.Get("RootPath").ToString() == "" {
-			logConfig.Set("RootPath", Config.RuntimeLogPath)
-		}
-		_ = vdlog.Use(map[string]interface{}(logConfig)) // 更新全局 StdLogger
-		_ = RegisterLogMiddleWare()                      // 日志中间件
+	// 等待退出信号
+	waitSignal("WebServer", errChan)
+	if err := ws.Shutdown(); err != nil {
+		fmt.Printf("[WebServer]服务关闭失败 error: %v \r\n", err)
 	}
+}
 
-	// 执行用户挂载函数
-	if len(UserFuncArray) > 0 {
-		for _, function := range UserFuncArray {
-			err := function()
-			if err != nil {
-				panic(err.Error())
+// 根据配置启动 http、https 监听, 均未开启时默认开启 http
+func listen(tag string, handler http.Handler, errChan chan error) ([]*http.Server, error) {
+	servers := make([]*http.Server, 0, 2)
+	enableHTTP := Config.EnableHTTP || !Config.EnableHTTPS
+	if Config.EnableHTTPS {
+		certLoader, err := NewCertLoader(Config.HTTPSCertFile, Config.HTTPSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		server := &http.Server{
+			Addr:      Config.HTTPSAddr,
+			Handler:   handler,
+			TLSConfig: &tls.Config{GetCertificate: certLoader.GetCertificate},
+		}
+		servers = append(servers, server)
+		go func() {
+			fmt.Printf("[%s]Listening and serving HTTPS on %s\r\n", tag, server.Addr)
+			if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				errChan <- err
 			}
+		}()
+	}
+	if enableHTTP {
+		server := &http.Server{Addr: Config.HTTPAddr, Handler: handler}
+		if Config.EnableHTTPS && Config.HTTPSRedirect { // http重定向至https
+			server.Handler = httpsRedirectHandler(Config.HTTPSAddr)
 		}
+		servers = append(servers, server)
+		go func() {
+			fmt.Printf("[%s]Listening and serving HTTP on %s\r\n", tag, server.Addr)
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errChan <- err
+			}
+		}()
 	}
+	return servers, nil
 }
 
-// 运行server
-func (ws *WebServer) Run() {
-	_ = ws.registerMiddleWare()     // 注册中间件
-	_ = ws.registerRouter()         // 注册路由
-	_ = ws.registerStatic()         // 注册静态文件
-	_ = ws.gin.Run(Config.HTTPAddr) // 运行gin
+// 等待 SIGINT/SIGTERM 信号或服务运行失败
+func waitSignal(tag string, errChan chan error) {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	select {
+	case sig := <-quit:
+		fmt.Printf("[%s]收到信号 %v, 开始关闭服务...\r\n", tag, sig)
+	case err := <-errChan:
+		fmt.Printf("[%s]服务运行失败 error: %v \r\n", tag, err)
+	}
+}
+
+// 关闭http服务, 等待处理中的请求完成(最长 Config.ShutdownTimeout 秒)
+func shutdownServers(servers []*http.Server) (err error) {
+	timeout := time.Duration(Config.ShutdownTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, server := range servers {
+		if shutdownErr := server.Shutdown(ctx); shutdownErr != nil {
+			err = shutdownErr
+		}
+	}
+	return err
+}
+
+// 关闭server, 等待处理中的请求完成(最长 Config.ShutdownTimeout 秒)后执行关闭函数并释放资源
+func (ws *WebServer) Shutdown() (err error) {
+	// 就绪检查失败, 等待负载均衡摘除流量
+	Health.SetShuttingDown()
+	if delay := healthOption().ShutdownDelay; delay > 0 && len(ws.servers) > 0 {
+		fmt.Printf("[WebServer]等待 %d 秒后关闭服务...\r\n", delay)
+		time.Sleep(time.Duration(delay) * time.Second)
+	}
+	err = shutdownServers(ws.servers)
+	runOnShutdown("WebServer")
+	return err
 }
 
 // 获取原生gin
@@ -109,21 +185,26 @@ func (ws *WebServer) registerMiddleWare() error {
 	return nil
 }
 
-// 注册路由
+// 注册路由, 简易路由与 Restful 路由均注册, 路径冲突时由 gin 报错
 func (ws *WebServer) registerRouter() error {
-	if Router.Mode == "General" { // 简易模式
-		if generalMap := Router.GetGeneralPath(); len(generalMap) > 0 {
-			for _, value := range generalMap {
-				for _, v := range value {
-					ws.gin.HEAD(v, ws.generalHandle)    // 注册 HEAD handle
-					ws.gin.GET(v, ws.generalHandle)     // 注册 GET handle
-					ws.gin.POST(v, ws.generalHandle)    // 注册 POST handle
-					ws.gin.OPTIONS(v, ws.generalHandle) // 注册 OPTIONS handle
+	// 简易模式
+	if generalMap := Router.GetGeneralPath(); len(generalMap) > 0 {
+		for group, value := range generalMap {
+			routerGroup := ws.gin.Group("", MiddleWare.GetGroupChain(group)...) // 分组中间件
+			for _, v := range value {
+				for _, method := range generalMethods { // 注册全部请求方法, 由 generalHandle 校验
+					routerGroup.Handle(method, v, ws.generalHandle)
 				}
 			}
 		}
-	} else if Router.Mode == "Restful" { // Restful模式
-		// ...
+	}
+	// Restful模式
+	for _, route := range Router.GetRestful() {
+		handlers := MiddleWare.GetGroupChain(route.Group)               // 分组中间件
+		handlers = append(handlers, route.MiddleWare...)                // 注册时指定的路由中间件
+		handlers = append(handlers, MiddleWare.GetRoute(route.Path)...) // MiddleWare.SetRoute 路由中间件
+		handlers = append(handlers, ws.restfulHandle(route))            // 控制器 handle
+		ws.gin.Handle(route.Method, route.Path, handlers...)
 	}
 	return nil
 }
@@ -166,63 +247,113 @@ func (ws *WebServer) generalHandle(context *gin.Context) {
 	if generalCtrl == nil {
 		panic(fmt.Sprintf("get controller failed by path '%s'", path))
 	}
+	context.Set("generalPath", path) // 控制器路径, 用于监控指标路由标签
+
+	// 请求方法校验, OPTIONS 未显式允许时自动响应
+	allowMethods := Router.GetAllowMethods(path)
+	if method := context.Request.Method; !methodAllowed(allowMethods, method) {
+		allow := strings.Join(normalizeMethods(append(append([]string{}, allowMethods...), http.MethodOptions)), ", ")
+		context.Header("Allow", allow)
+		if method == http.MethodOptions {
+			if context.GetHeader("Origin") != "" && context.GetHeader("Access-Control-Request-Method") != "" { // 跨域预检
+				context.Header("Access-Control-Allow-Methods", allow)
+			}
+			context.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		context.Abort()
+		ws.Response(context, NewResult().SetStatus(http.StatusMethodNotAllowed).SetMsg("Method Not Allowed"))
+		return
+	}
 
+	// 路由中间件, 简易模式多个控制器共用同一gin路由, 故在控制器前依次执行(c.Next()后的逻辑不包裹控制器)
+	for _, handlerFunc := range MiddleWare.GetRoute(path) {
+		handlerFunc(context)
+		if context.IsAborted() {
+			return
+		}
+	}
+	ws.controllerHandle(context, generalCtrl)
+}
+
+// Restful路由 handle
+func (ws *WebServer) restfulHandle(route *RestfulRoute) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		defer ws.errorCatch(context)
+		ws.controllerHandle(context, route.Controller)
+	}
+}
+
+// 控制器生命周期
+func (ws *WebServer) controllerHandle(context *gin.Context, controllerTpl ControllerInterface) {
 	// 克隆结构体
-	ctrlType := reflect.TypeOf(generalCtrl).Elem()
+	ctrlType := reflect.TypeOf(controllerTpl).Elem()
 	controller, ok := reflect.New(ctrlType).Interface().(ControllerInterface)
 	if !ok {
 		panic("controller is not ControllerInterface")
+	} else if err := AppStorage.Inject(controller, context); err != nil { // 注入 inject 标签字段
+		panic(err)
 	} else {
 		controller.Init(context, NewResult())
 	}
 
-	// 执行 BeforeExec()
+	span := traceController(context, controller) // 控制器 span
+	defer span.End()
+	defer controller.Finally() // 执行 Finally(), 发生 panic 时同样执行
+
+	// 执行 BeforeExec(), 状态码非200时阻断执行
 	controller.BeforeExec()
 	if result := controller.Result(); result.Status != 200 {
 		ws.Response(context, result)
 		return
 	}
 
-	// 执行 Exec()
+	// 执行 Exec()、AfterExec()
 	controller.Exec()
-	if result := controller.Result(); result.Status != 200 {
-		ws.Response(context, result)
-		return
+	controller.AfterExec()
+
+	// 渲染 Result, 控制器已直接写入响应或通过 context.Status 设置状态码时跳过
+	if !context.Writer.Written() && context.Writer.Status() == http.StatusOK {
+		ws.Response(context, controller.Result())
 	}
 }
 
-// 异常响应
+// 响应 Result
 func (ws *WebServer) Response(context *gin.Context, result *Result) {
-	if result.Status == 900 {
-		result.Status = 200
-	}
-	switch result.Type {
-	case "String":
-		context.String(result.Status, result.Msg)
-	case "Json":
-		context.Set("response", result.Data)
-		context.JSON(result.Status, result.Data)
-	case "Html":
-		context.HTML(result.Status, result.Msg, result.Data)
+	if httpStatus, ok := GetStatusMapping(result.Status); ok {
+		if result.Code == 0 { // 未设置业务码时使用原状态码
+			result.Code = result.Status
+		}
+		result.Status = httpStatus
 	}
+	Render(context, result)
 }
 
-// 异常捕获
+// 异常捕获, RecoverPanic 关闭时交由 gin.Recovery 处理
 func (ws *WebServer) errorCatch(context *gin.Context) {
+	if !Config.RecoverPanic {
+		return
+	}
 	if r := recover(); r != nil {
-		err := Error(r)
-		if err != nil {
-			// 异常响应
-			context.JSON(200, map[string]interface{}{
-				"code": 9999,
-				"msg":  "系统貌似出问题了~",
-				"data": err.Error(),
-			})
-			// 记录错误日志
-			logger, ok := context.Get("logger")
-			if ok {
-				logger.(*vdlog.Logger).Error(err)
-			}
+		stackErr := Error(r)
+		err, ok := r.(error)
+		if !ok {
+			err = errors.New(fmt.Sprint(r))
+		}
+
+		// 记录错误日志
+		if logger, ok := context.Get("logger"); ok {
+			logger.(*vdlog.Logger).Error(stackErr)
+		} else {
+			vdlog.StdLogger.Error(stackErr)
+		}
+
+		// 异常响应
+		context.Set("error", err)
+		if Config.RecoverFunc != nil {
+			Config.RecoverFunc(context)
+			return
 		}
+		RenderError(context, err, stackErr.Error())
 	}
 }
@@ -0,0 +1,82 @@
+package ratgo
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vdongchina/ratgo/utils/vdlog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// 记录生命周期方法的执行顺序
+var lifecycleCalls []string
+
+type lifecycleController struct {
+	Controller
+	Logger *vdlog.Logger `inject:"logger"`
+}
+
+func (lc *lifecycleController) BeforeExec() {
+	if lc.Logger != nil {
+		lifecycleCalls = append(lifecycleCalls, "Inject")
+	}
+	lifecycleCalls = append(lifecycleCalls, "BeforeExec")
+	if lc.Context().Query("mode") == "block" {
+		lc.Result().SetStatus(http.StatusForbidden).SetMsg("Forbidden")
+	}
+}
+
+func (lc *lifecycleController) Exec() {
+	lifecycleCalls = append(lifecycleCalls, "Exec")
+	switch lc.Context().Query("mode") {
+	case "panic":
+		panic("boom")
+	case "status":
+		lc.Context().Status(http.StatusAccepted)
+	case "write":
+		lc.Context().String(http.StatusOK, "written")
+	default:
+		lc.Result().SetData(gin.H{"id": 1})
+	}
+}
+
+func (lc *lifecycleController) AfterExec() {
+	lifecycleCalls = append(lifecycleCalls, "AfterExec")
+}
+
+func (lc *lifecycleController) Finally() {
+	lifecycleCalls = append(lifecycleCalls, "Finally")
+}
+
+func TestControllerHandle_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ws := &WebServer{gin: gin.New()}
+	ws.gin.GET("/lifecycle", ws.restfulHandle(&RestfulRoute{Controller: &lifecycleController{}}))
+
+	cases := []struct {
+		mode   string
+		calls  []string
+		status int
+		body   string
+	}{
+		{"", []string{"Inject", "BeforeExec", "Exec", "AfterExec", "Finally"}, http.StatusOK, `{"id":1}`},
+		{"block", []string{"Inject", "BeforeExec", "Finally"}, http.StatusForbidden, `""`},
+		{"panic", []string{"Inject", "BeforeExec", "Exec", "Finally"}, http.StatusInternalServerError, ""},
+		{"status", []string{"Inject", "BeforeExec", "Exec", "AfterExec", "Finally"}, http.StatusAccepted, ""},
+		{"write", []string{"Inject", "BeforeExec", "Exec", "AfterExec", "Finally"}, http.StatusOK, "written"},
+	}
+	for _, c := range cases {
+		lifecycleCalls = nil
+		req := httptest.NewRequest(http.MethodGet, "/lifecycle?mode="+c.mode, nil)
+		req.Header.Set("Accept", "application/json")
+		recorder := httptest.NewRecorder()
+		ws.gin.ServeHTTP(recorder, req)
+		if !reflect.DeepEqual(lifecycleCalls, c.calls) {
+			t.Errorf("mode '%s' expect %v, but receive %v", c.mode, c.calls, lifecycleCalls)
+		}
+		if recorder.Code != c.status || (c.body != "" && recorder.Body.String() != c.body) {
+			t.Errorf("mode '%s' receive %d %s", c.mode, recorder.Code, recorder.Body.String())
+		}
+	}
+}
@@ -8,3 +8,595 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 package ratgo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/vdongchina/ratgo/utils/encrypt"
+	"github.com/vdongchina/ratgo/utils/vdlog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 连接参数
+const (
+	wsWriteWait      = 10 * time.Second    // 写超时
+	wsPongWait       = 60 * time.Second    // 读取 pong 超时
+	wsPingPeriod     = wsPongWait * 9 / 10 // ping 周期
+	wsMaxMessageSize = 1 << 20             // 消息最大字节数
+	wsSendBufferSize = 256                 // 发送队列长度
+)
+
+/**************************************** 消息结构 ****************************************/
+// 请求消息
+type WsMessage struct {
+	Action    string          `json:"action"`    // 动作名称, 对应 ActionMap 的 key
+	RequestId string          `json:"requestId"` // 请求id, 为空时自动生成
+	Data      json.RawMessage `json:"data"`      // 请求数据
+}
+
+// 响应消息
+type WsResponse struct {
+	Action    string      `json:"action"`
+	RequestId string      `json:"requestId"`
+	Status    int         `json:"status"`
+	Msg       string      `json:"msg"`
+	Data      interface{} `json:"data"`
+}
+
+/**************************************** 控制器 ****************************************/
+// websocket控制器接口
+type WsControllerInterface interface {
+	Init(client *WsClient, message *WsMessage, logger *vdlog.Logger, result *Result) // 初始化
+	Client() *WsClient                                                               // 获取连接
+	Message() *WsMessage                                                             // 获取请求消息
+	BeforeExec()                                                                     // 动作前执行方法
+	Exec()                                                                           // 动作方法
+	Result() *Result                                                                 // 控制响应
+}
+
+// websocket控制器
+type WsController struct {
+	client  *WsClient
+	message *WsMessage
+	logger  *vdlog.Logger
+	result  *Result
+}
+
+// 控制器初始化方法
+func (c *WsController) Init(client *WsClient, message *WsMessage, logger *vdlog.Logger, result *Result) {
+	c.client = client
+	c.message = message
+	c.logger = logger
+	c.result = result
+}
+
+// 获取连接
+func (c *WsController) Client() *WsClient {
+	return c.client
+}
+
+// 获取请求消息
+func (c *WsController) Message() *WsMessage {
+	return c.message
+}
+
+// 获取日志对象(已设置 requestId)
+func (c *WsController) Logger() *vdlog.Logger {
+	return c.logger
+}
+
+// 解析请求数据
+func (c *WsController) Bind(obj interface{}) error {
+	if len(c.message.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(c.message.Data, obj)
+}
+
+// 前置方法
+func (c *WsController) BeforeExec() {
+
+}
+
+// 执行方法
+func (c *WsController) Exec() {
+
+}
+
+// 结果方法
+func (c *WsController) Result() *Result {
+	return c.result
+}
+
+/**************************************** 路由存储器 ****************************************/
+// websocket动作容器
+type ActionMap map[string]WsControllerInterface
+
+// websocket存储器
+type WebsocketStorage struct {
+	path      []string
+	actionMap ActionMap
+	upgrader  websocket.Upgrader
+}
+
+// websocket对象
+var Websocket *WebsocketStorage
+
+func init() {
+	Websocket = &WebsocketStorage{
+		path:      make([]string, 0),
+		actionMap: ActionMap{},
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}
+}
+
+// 设置连接路径
+func (ws *WebsocketStorage) SetPath(path ...string) {
+	ws.path = append(ws.path, path...)
+}
+
+// 获取连接路径, 包含配置项 WebsocketPath(多个使用,拼接)
+func (ws *WebsocketStorage) GetPath() []string {
+	pathSlice := make([]string, 0)
+	exists := map[string]bool{}
+	for _, v := range append(strings.Split(Config.WebsocketPath, ","), ws.path...) {
+		if v = strings.TrimSpace(v); v != "" && !exists[v] {
+			exists[v] = true
+			pathSlice = append(pathSlice, v)
+		}
+	}
+	return pathSlice
+}
+
+// 注册动作, group不为空时动作名称为 group/action
+func (ws *WebsocketStorage) General(group string, actions ActionMap) {
+	group = strings.Trim(strings.TrimSpace(group), "/")
+	for key, value := range actions {
+		key = strings.Trim(strings.TrimSpace(key), "/")
+		if group != "" {
+			key = fmt.Sprintf("%s/%s", group, key)
+		}
+		ws.actionMap[key] = value // 存储控制器模板
+	}
+}
+
+// 获取动作对应控制器
+func (ws *WebsocketStorage) GetAction(action string) WsControllerInterface {
+	if controller, ok := ws.actionMap[strings.Trim(strings.TrimSpace(action), "/")]; ok {
+		return controller
+	}
+	return nil
+}
+
+// 设置 Upgrader(跨域校验、缓冲区等)
+func (ws *WebsocketStorage) SetUpgrader(upgrader websocket.Upgrader) {
+	ws.upgrader = upgrader
+}
+
+/**************************************** 连接 ****************************************/
+// websocket连接
+type WsClient struct {
+	id        string
+	conn      *websocket.Conn
+	context   *gin.Context
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// 获取连接id
+func (c *WsClient) Id() string {
+	return c.id
+}
+
+// 获取握手请求的 gin.Context(只读副本)
+func (c *WsClient) Context() *gin.Context {
+	return c.context
+}
+
+// 发送消息, 非 []byte、string 类型数据使用json序列化
+func (c *WsClient) Send(v interface{}) error {
+	var data []byte
+	switch value := v.(type) {
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	select {
+	case <-c.done:
+		return errors.New(fmt.Sprintf("websocket client '%s' is closed", c.id))
+	default:
+	}
+	select {
+	case c.send <- data:
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("websocket client '%s' send buffer is full", c.id))
+	}
+}
+
+// 加入房间
+func (c *WsClient) Join(room string) {
+	Hub.Join(room, c)
+}
+
+// 离开房间
+func (c *WsClient) Leave(room string) {
+	Hub.Leave(room, c)
+}
+
+// 关闭连接, 由 writePump 发送队列中的消息及关闭帧后关闭底层连接
+func (c *WsClient) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		Hub.Remove(c)
+	})
+}
+
+// 写消息循环, 唯一写入及关闭底层连接的协程
+func (c *WsClient) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Close()
+		_ = c.conn.Close()
+	}()
+	for {
+		select {
+		case data := <-c.send:
+			if err := c.write(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.write(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			for len(c.send) > 0 { // 发送队列中剩余的消息
+				if err := c.write(websocket.TextMessage, <-c.send); err != nil {
+					return
+				}
+			}
+			_ = c.write(websocket.CloseMessage, []byte{})
+			return
+		}
+	}
+}
+
+// 写入消息
+func (c *WsClient) write(messageType int, data []byte) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// 生成连接id, 不使用客户端可控的请求头
+func newWsClientId() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+/**************************************** 连接中心 ****************************************/
+// 连接中心
+type WsHub struct {
+	lock    sync.RWMutex
+	clients map[string]*WsClient
+	rooms   map[string]map[string]*WsClient
+}
+
+// hub对象
+var Hub *WsHub
+
+func init() {
+	Hub = &WsHub{
+		clients: map[string]*WsClient{},
+		rooms:   map[string]map[string]*WsClient{},
+	}
+}
+
+// 添加连接
+func (h *WsHub) Add(client *WsClient) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.clients[client.id] = client
+}
+
+// 移除连接, 同时离开全部房间
+func (h *WsHub) Remove(client *WsClient) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.clients, client.id)
+	for room, members := range h.rooms {
+		delete(members, client.id)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+// 关闭全部连接
+func (h *WsHub) CloseAll() {
+	h.lock.RLock()
+	clients := make([]*WsClient, 0, len(h.clients))
+	for _, client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.lock.RUnlock()
+	for _, client := range clients {
+		client.Close()
+	}
+}
+
+// 根据id获取连接
+func (h *WsHub) Get(id string) *WsClient {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.clients[id]
+}
+
+// 连接数量
+func (h *WsHub) Count() int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return len(h.clients)
+}
+
+// 加入房间
+func (h *WsHub) Join(room string, client *WsClient) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if _, ok := h.rooms[room]; !ok {
+		h.rooms[room] = map[string]*WsClient{}
+	}
+	h.rooms[room][client.id] = client
+}
+
+// 离开房间
+func (h *WsHub) Leave(room string, client *WsClient) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if members, ok := h.rooms[room]; ok {
+		delete(members, client.id)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+// 获取房间内连接, room为空时获取全部连接
+func (h *WsHub) Clients(room string) []*WsClient {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	members := h.clients
+	if room != "" {
+		members = h.rooms[room]
+	}
+	clients := make([]*WsClient, 0, len(members))
+	for _, client := range members {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// 发送消息至指定连接
+func (h *WsHub) Send(id string, v interface{}) error {
+	client := h.Get(id)
+	if client == nil {
+		return errors.New(fmt.Sprintf("websocket client '%s' is not exists", id))
+	}
+	return client.Send(v)
+}
+
+// 广播消息至全部连接
+func (h *WsHub) Broadcast(v interface{}) {
+	h.BroadcastRoom("", v)
+}
+
+// 广播消息至房间内连接
+func (h *WsHub) BroadcastRoom(room string, v interface{}) {
+	data, err := json.Marshal(v)
+	if value, ok := v.([]byte); ok {
+		data, err = value, nil
+	} else if value, ok := v.(string); ok {
+		data, err = []byte(value), nil
+	}
+	if err != nil {
+		vdlog.StdLogger.Error(fmt.Sprintf("websocket broadcast marshal failed. error:%v", err))
+		return
+	}
+	for _, client := range h.Clients(room) {
+		_ = client.Send(data)
+	}
+}
+
+/**************************************** 服务 ****************************************/
+// Websocket server.
+type WebsocketServer struct {
+	gin     *gin.Engine
+	servers []*http.Server
+}
+
+// 获取websocket实例
+func NewWebsocketServer() *WebsocketServer {
+	return &WebsocketServer{
+		gin: gin.Default(),
+	}
+}
+
+// 初始化
+func (wss *WebsocketServer) Init() {
+	tag := "WebsocketServer初始化"
+	initConfig(tag)   // 配置初始化
+	initResource(tag) // 初始化 mysql、redis
+	if initLogger() { // 系统日志
+		_ = RegisterLogMiddleWare() // 日志中间件
+	}
+	if err := setTrustedProxies(wss.gin); err != nil { // 可信代理
+		panic(err)
+	}
+	runUserFunc() // 执行用户挂载函数
+}
+
+// 运行server, 收到 SIGINT/SIGTERM 信号后优雅关闭
+func (wss *WebsocketServer) Run() {
+	if middleWare := MiddleWare.GetGlobal(); len(middleWare) > 0 {
+		wss.gin.Use(middleWare...) // 注册全局中间件
+	}
+	for _, path := range Websocket.GetPath() {
+		wss.gin.GET(path, wss.upgradeHandle) // 注册握手 handle
+	}
+	runOnStart() // 执行服务启动函数
+
+	// 运行http、https服务
+	errChan := make(chan error, 2)
+	servers, err := listen("WebsocketServer", wss.gin, errChan)
+	if err != nil {
+		panic(err)
+	}
+	wss.servers = servers
+
+	// 等待退出信号
+	waitSignal("WebsocketServer", errChan)
+	if err := wss.Shutdown(); err != nil {
+		fmt.Printf("[WebsocketServer]服务关闭失败 error: %v \r\n", err)
+	}
+}
+
+// 关闭server, 停止接收新连接并关闭全部websocket连接后执行关闭函数并释放资源
+func (wss *WebsocketServer) Shutdown() error {
+	err := shutdownServers(wss.servers)
+	Hub.CloseAll()
+	runOnShutdown("WebsocketServer")
+	return err
+}
+
+// 获取原生gin
+func (wss *WebsocketServer) Gin() *gin.Engine {
+	return wss.gin
+}
+
+// 握手 handle
+func (wss *WebsocketServer) upgradeHandle(context *gin.Context) {
+	conn, err := Websocket.upgrader.Upgrade(context.Writer, context.Request, nil)
+	if err != nil {
+		return // Upgrade 已响应错误
+	}
+
+	client := &WsClient{
+		id:      newWsClientId(),
+		conn:    conn,
+		context: context.Copy(),
+		send:    make(chan []byte, wsSendBufferSize),
+		done:    make(chan struct{}),
+	}
+	Hub.Add(client)
+	go client.writePump()
+
+	// 读消息循环
+	defer client.Close()
+	conn.SetReadLimit(wsMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		wss.dispatch(client, data)
+	}
+}
+
+// 消息分发
+func (wss *WebsocketServer) dispatch(client *WsClient, data []byte) {
+	message := &WsMessage{}
+	result := NewResult()
+	if err := json.Unmarshal(data, message); err != nil {
+		result.SetStatus(400).SetMsg(fmt.Sprintf("decode message failed. error:%v", err))
+	}
+
+	// 生成 requestId 并记录请求日志
+	if message.RequestId == "" {
+		message.RequestId = encrypt.Md5(client.id, message.Action, fmt.Sprintf("%d", time.Now().UnixNano()))
+	}
+	logger := vdlog.Clone().SetLogId(message.RequestId)
+	logger.Info(map[string]interface{}{
+		"clientId": client.id,
+		"action":   message.Action,
+		"size":     len(data),
+	})
+
+	// 响应
+	defer func() {
+		if r := recover(); r != nil {
+			err := Error(r)
+			logger.Error(err)
+			result.SetStatus(500).SetMsg("internal server error")
+		}
+		response := &WsResponse{
+			Action:    message.Action,
+			RequestId: message.RequestId,
+			Status:    result.Status,
+			Msg:       result.Msg,
+			Data:      result.Data,
+		}
+		logger.Info(map[string]interface{}{
+			"clientId": client.id,
+			"action":   response.Action,
+			"status":   response.Status,
+			"msg":      response.Msg,
+		})
+		_ = client.Send(response)
+	}()
+	if result.Status != 200 {
+		return
+	}
+
+	// 获取控制器
+	controllerTpl := Websocket.GetAction(message.Action)
+	if controllerTpl == nil {
+		result.SetStatus(404).SetMsg(fmt.Sprintf("action '%s' is not defined", message.Action))
+		return
+	}
+
+	// 克隆结构体
+	ctrlType := reflect.TypeOf(controllerTpl).Elem()
+	controller, ok := reflect.New(ctrlType).Interface().(WsControllerInterface)
+	if !ok {
+		panic("controller is not WsControllerInterface")
+	}
+	if err := AppStorage.Inject(controller, client.context); err != nil { // 注入 inject 标签字段
+		panic(err)
+	}
+	controller.Init(client, message, logger, result)
+
+	// 执行 BeforeExec()
+	controller.BeforeExec()
+	if controller.Result().Status != 200 {
+		return
+	}
+
+	// 执行 Exec()
+	controller.Exec()
+}
@@ -0,0 +1,129 @@
+package ratgo
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebsocketClientId_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	wss := NewWebsocketServer()
+	wss.gin.GET("/ws", func(context *gin.Context) {
+		context.Set("requestId", context.GetHeader("X-Request-Id")) // 客户端可控的 requestId
+		context.Next()
+	}, wss.upgradeHandle)
+	server := httptest.NewServer(wss.gin)
+	defer server.Close()
+
+	header := http.Header{"X-Request-Id": {"fixed"}}
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	for i := 0; i < 2; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+	}
+	for i := 0; i < 50 && Hub.Count() < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 相同 requestId 的连接互不覆盖
+	if Hub.Count() != 2 || Hub.Get("fixed") != nil {
+		t.Fatal(Hub.Count())
+	}
+	Hub.CloseAll()
+	if Hub.Count() != 0 {
+		t.Fatal(Hub.Count())
+	}
+}
+
+type wsEchoController struct {
+	WsController
+}
+
+func (c *wsEchoController) BeforeExec() {
+	if c.Message().Action == "chat/deny" {
+		c.Result().SetStatus(403).SetMsg("forbidden")
+	}
+}
+
+func (c *wsEchoController) Exec() {
+	data := map[string]interface{}{}
+	if err := c.Bind(&data); err != nil {
+		c.Result().SetStatus(400).SetMsg(err.Error())
+		return
+	}
+	switch data["do"] {
+	case "panic":
+		panic("boom")
+	case "close": // 关闭前发送的消息仍送达
+		_ = c.Client().Send("bye")
+		c.Client().Close()
+	}
+	c.Result().SetData(data)
+}
+
+func TestWebsocketDispatch_01(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer func(actionMap ActionMap) { Websocket.actionMap = actionMap }(Websocket.actionMap)
+	Websocket.actionMap = ActionMap{}
+	Websocket.General("/chat/", ActionMap{"echo": &wsEchoController{}, "deny": &wsEchoController{}})
+	wss := NewWebsocketServer()
+	wss.gin.GET("/ws", wss.upgradeHandle)
+	server := httptest.NewServer(wss.gin)
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cases := []struct {
+		message string
+		status  int
+		action  string
+		data    interface{}
+	}{
+		{`{"action":"chat/echo","requestId":"r1","data":{"text":"hi"}}`, 200, "chat/echo", map[string]interface{}{"text": "hi"}},
+		{`{"action":"/chat/echo/","data":{}}`, 200, "/chat/echo/", map[string]interface{}{}},
+		{`{"action":"chat/deny"}`, 403, "chat/deny", ""},
+		{`{"action":"chat/missing"}`, 404, "chat/missing", ""},
+		{`{"action":"chat/echo","data":[1]}`, 400, "chat/echo", ""},
+		{`{"action":`, 400, "", ""},
+		{`{"action":"chat/echo","data":{"do":"panic"}}`, 500, "chat/echo", ""},
+	}
+	for _, c := range cases {
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(c.message))
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		response := &WsResponse{}
+		if err := conn.ReadJSON(response); err != nil {
+			t.Fatal(c.message, err)
+		}
+		if response.Status != c.status || response.Action != c.action || response.RequestId == "" {
+			t.Errorf("'%s' receive %+v", c.message, response)
+		}
+		if c.status == 200 {
+			data, _ := json.Marshal(response.Data)
+			expect, _ := json.Marshal(c.data)
+			if string(data) != string(expect) || (strings.Contains(c.message, "r1") && response.RequestId != "r1") {
+				t.Errorf("'%s' receive %+v", c.message, response)
+			}
+		}
+	}
+
+	// 关闭时先发送队列中的消息, 再发送关闭帧
+	_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"chat/echo","data":{"do":"close"}}`))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "bye" {
+		t.Fatal(string(data), err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNoStatusReceived) {
+		t.Fatal(err)
+	}
+}